@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const BACKUP_PREFIX = "svalutation-"
+
+// Writes a consistent snapshot of the live database to path. VACUUM INTO
+// runs inside a read transaction, so concurrent writers never tear the copy.
+func backupDatabase(db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+func backupFileName(t time.Time) string {
+	return BACKUP_PREFIX + t.Format("20060102-150405") + ".db"
+}
+
+// Removes all but the newest keep snapshots in dir. Snapshot names sort
+// chronologically, so lexical order is enough.
+func rotateBackups(dir string, keep int) error {
+	files, err := filepath.Glob(filepath.Join(dir, BACKUP_PREFIX+"*.db"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for len(files) > keep {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		slog.Info("Removed old backup", "file", files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// Takes a snapshot into dir every interval, keeping the newest keep files.
+func scheduleBackups(db *sql.DB, dir string, interval time.Duration, keep int) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		slog.Error("Couldn't create backup directory", "dir", dir, "err", err)
+		return
+	}
+
+	for range time.Tick(interval) {
+		path := filepath.Join(dir, backupFileName(time.Now()))
+		if err := backupDatabase(db, path); err != nil {
+			slog.Error("Scheduled backup failed", "err", err)
+			continue
+		}
+		slog.Info("Wrote backup", "file", path)
+		if keep > 0 {
+			if err := rotateBackups(dir, keep); err != nil {
+				slog.Error("Backup rotation failed", "err", err)
+			}
+		}
+	}
+}
+
+// Checks that path is an intact database this binary knows how to run.
+func validateSnapshot(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	snapshot, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var integrity string
+	err = snapshot.QueryRow("PRAGMA integrity_check").Scan(&integrity)
+	if err != nil {
+		return err
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("integrity check failed: %s", integrity)
+	}
+
+	var version int
+	err = snapshot.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version > SCHEMA_VERSION {
+		return fmt.Errorf("snapshot schema version %d is newer than supported version %d", version, SCHEMA_VERSION)
+	}
+
+	for _, table := range []string{"classes", "students", "teachers", "remarks", "observations", "classes_teachers", "credentials"} {
+		var name string
+		err = snapshot.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err != nil {
+			return fmt.Errorf("snapshot has no %s table", table)
+		}
+	}
+	return nil
+}
+
+// Replaces the database at dbPath with the snapshot. The previous database
+// is kept next to it so a bad restore can be undone by hand. The server must
+// be stopped; older snapshots are migrated on the next start.
+func restoreDatabase(dbPath string, snapshotPath string) error {
+	err := validateSnapshot(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := dbPath + ".restore"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	dst.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// The journal files of the old database go with it, SQLite would
+	// otherwise replay them onto the snapshot
+	previous := dbPath + ".pre-restore-" + time.Now().Format("20060102-150405")
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if _, err := os.Stat(dbPath + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(dbPath+suffix, previous+suffix); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		slog.Info("Kept previous database", "file", previous+suffix)
+	}
+	return os.Rename(tmpPath, dbPath)
+}
+
+// Staff logins have a password and aren't tied to a teacher. Only they may
+// download snapshots, which hold every credential hash.
+func isStaffLogin(q querier, r *http.Request) (bool, error) {
+	var teacher *int64
+	err := q.QueryRow("SELECT teacher FROM credentials WHERE user = ?", requestUser(r)).Scan(&teacher)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil && teacher == nil, err
+}
+
+func downloadBackup(w http.ResponseWriter, r *http.Request) {
+	staff, err := isStaffLogin(DB, r)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	if !staff {
+		errorCheck(&w, fmt.Errorf("backups are only open to staff logins"), 403)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "svalutation-backup")
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := backupFileName(time.Now())
+	path := filepath.Join(dir, name)
+	err = backupDatabase(DB, path)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+
+	file, err := os.Open(path)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+	return
+}
+
+// backup [file|dir]: snapshot the database, by default into the current directory.
+func backupCommand(args []string) error {
+	path := backupFileName(time.Now())
+	if len(args) > 0 {
+		path = args[0]
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, backupFileName(time.Now()))
+		}
+	}
+	err := backupDatabase(DB, path)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+// restore <file>: swap the snapshot in place of the database.
+func restoreCommand(args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: restore <snapshot>")
+	}
+	return restoreDatabase(CONFIG.DatabasePath, args[0])
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Evidence rows with the same content share a blob, so a blob can only go
+// once nothing refers to it. BLOB_LOCK keeps that check from racing with
+// uploads: an upload holds it for reading from storing its blobs until its
+// row is committed, removals hold it for writing.
+var BLOB_LOCK sync.RWMutex
+
+// Unreferenced blobs younger than this survive garbage collection
+const BLOB_GC_GRACE = time.Hour
+
+const BLOB_REFERENCES = "SELECT blob FROM evidence UNION SELECT thumbnail FROM evidence WHERE thumbnail IS NOT NULL"
+
+// Deletes the blobs among keys nothing refers to any more. A failure only
+// leaves a file for garbage collection, so it is logged rather than
+// returned. Must not be called while holding BLOB_LOCK.
+func releaseBlobs(keys ...string) {
+	BLOB_LOCK.Lock()
+	defer BLOB_LOCK.Unlock()
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		var used bool
+		err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM evidence WHERE blob = ?1 OR thumbnail = ?1)", key).Scan(&used)
+		if err == nil && !used {
+			err = BLOBS.Delete(key)
+		}
+		if err != nil {
+			slog.Warn("Couldn't release blob", "blob", key, "err", err)
+		}
+	}
+}
+
+// Deletes every blob that no evidence row refers to and that was stored
+// more than grace ago, and returns how many went.
+func collectBlobGarbage(grace time.Duration) (int, error) {
+	BLOB_LOCK.Lock()
+	defer BLOB_LOCK.Unlock()
+
+	rows, err := DB.Query(BLOB_REFERENCES)
+	if err != nil {
+		return 0, err
+	}
+	used := map[string]bool{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		used[key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-grace)
+	removed := 0
+	err = BLOBS.Walk(func(key string, stored time.Time) error {
+		if used[key] || stored.After(cutoff) {
+			return nil
+		}
+		if err := BLOBS.Delete(key); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func scheduleBlobGC(interval time.Duration) {
+	for range time.Tick(interval) {
+		removed, err := collectBlobGarbage(BLOB_GC_GRACE)
+		if err != nil {
+			slog.Error("Blob garbage collection failed", "err", err)
+			continue
+		}
+		if removed > 0 {
+			slog.Info("Removed unreferenced blobs", "count", removed)
+		}
+	}
+}
+
+func hashBlob(key string) (string, error) {
+	content, err := BLOBS.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Rehashes every evidence file, records the checksum of files stored
+// before checksums were, makes missing thumbnails and reports files that
+// are gone or changed.
+func verifyBlobs() error {
+	evidence, err := queryEvidence(DB, "SELECT * FROM evidence ORDER BY id")
+	if err != nil {
+		return err
+	}
+	damaged := 0
+	for _, file := range evidence {
+		sum, err := hashBlob(file.Blob)
+		switch {
+		case err != nil:
+			fmt.Printf("evidence %d: %v\n", file.Id, err)
+			damaged++
+			continue
+		case file.Sha256 == nil:
+			_, err = DB.Exec("UPDATE evidence SET sha256 = ? WHERE id = ?", sum, file.Id)
+		case *file.Sha256 != sum:
+			fmt.Printf("evidence %d: content doesn't match checksum %s\n", file.Id, *file.Sha256)
+			damaged++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if file.Thumbnail == nil {
+			if err := backfillThumbnail(file); err != nil {
+				fmt.Printf("evidence %d: no thumbnail: %v\n", file.Id, err)
+			}
+		}
+	}
+	if damaged > 0 {
+		return fmt.Errorf("%d of %d evidence files are damaged", damaged, len(evidence))
+	}
+	fmt.Println("all", len(evidence), "evidence files are intact")
+	return nil
+}
+
+func backfillThumbnail(file Evidence) error {
+	BLOB_LOCK.RLock()
+	thumbnail, err := makeThumbnail(file.Blob, file.MediaType)
+	if err == nil && thumbnail != "" {
+		_, err = DB.Exec("UPDATE evidence SET thumbnail = ? WHERE id = ?", thumbnail, file.Id)
+	}
+	BLOB_LOCK.RUnlock()
+	if err != nil && thumbnail != "" {
+		releaseBlobs(thumbnail)
+	}
+	return err
+}
+
+// blobs gc|verify: sweep unreferenced blobs now, or check every evidence
+// file against its checksum.
+func blobsCommand(subcommand string) error {
+	err := migrate(DB)
+	if err != nil {
+		return err
+	}
+	switch subcommand {
+	case "blobs gc":
+		removed, err := collectBlobGarbage(BLOB_GC_GRACE)
+		if err != nil {
+			return err
+		}
+		fmt.Println("removed", removed, "unreferenced blobs")
+		return nil
+	case "blobs verify":
+		return verifyBlobs()
+	}
+	return errors.New("usage: blobs gc|verify")
+}
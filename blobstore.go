@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Where evidence files and their thumbnails live. Blobs are content
+// addressed: the key is the SHA-256 of the content, so storing the same
+// file twice keeps a single copy. The database only keeps keys, so the
+// store can be swapped for another implementation.
+type BlobStore interface {
+	// Stores the content of r and returns its key and size.
+	Put(r io.Reader) (string, int64, error)
+	Open(key string) (io.ReadSeekCloser, error)
+	// Deleting a missing blob is not an error.
+	Delete(key string) error
+	// Calls fn with every blob and the last time it was stored.
+	Walk(fn func(key string, stored time.Time) error) error
+}
+
+var BLOBS BlobStore
+
+// Keeps every blob in a file of dir, spread over subdirectories named
+// after the first two characters of the key.
+type localBlobStore struct {
+	dir string
+}
+
+func newLocalBlobStore(dir string) *localBlobStore {
+	return &localBlobStore{dir: dir}
+}
+
+func (s *localBlobStore) path(key string) (string, error) {
+	if len(key) < 3 || filepath.Base(key) != key || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+// Hashes the content while writing it to a temporary file, so a failed
+// upload never leaves half a blob behind. Content already stored is only
+// touched, which keeps garbage collection off it for a while.
+func (s *localBlobStore) Put(r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return "", 0, err
+	}
+	file, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), r)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	key := hex.EncodeToString(hash.Sum(nil))
+	path, err := s.path(key)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		return key, size, os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", 0, err
+	}
+	return key, size, os.Rename(file.Name(), path)
+}
+
+func (s *localBlobStore) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Temporary files of uploads in progress start with a dot and are skipped.
+func (s *localBlobStore) Walk(fn func(key string, stored time.Time) error) error {
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(entry.Name(), info.ModTime())
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
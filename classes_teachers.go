@@ -0,0 +1,250 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// A teacher is linked to a class at most once, leading it or supporting
+// the lead in co-teaching. Unlinking a teacher also takes away the subjects
+// they taught in the class.
+
+var TEACHER_ROLES = []string{"lead", "support"}
+
+const DEFAULT_TEACHER_ROLE = "lead"
+
+func validateTeacherRole(role string) error {
+	if !slices.Contains(TEACHER_ROLES, role) {
+		return invalid("role %q must be one of %v", role, TEACHER_ROLES)
+	}
+	return nil
+}
+
+func validateClassTeacher(q querier, teacherId int64, classId int64, role string) error {
+	if err := checkExists(q, "teacher", teacherId); err != nil {
+		return err
+	}
+	if err := checkExists(q, "class", classId); err != nil {
+		return err
+	}
+	if err := validateTeacherRole(role); err != nil {
+		return err
+	}
+	var found string
+	err := q.QueryRow("SELECT role FROM classes_teachers WHERE teacher_id = ? AND class_id = ?", teacherId, classId).Scan(&found)
+	if err == nil {
+		return conflict("teacher %d is already linked to class %d as %s", teacherId, classId, found)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// Makes classIds the classes of a teacher. Links to classes the teacher
+// keeps are left alone with their role, new ones are lead.
+func setTeacherClasses(tx *sql.Tx, teacherId int64, classIds []int64) error {
+	current, err := queryRows(tx, func(row scanner, classId *int64) error {
+		return row.Scan(classId)
+	}, "SELECT class_id FROM classes_teachers WHERE teacher_id = ?", teacherId)
+	if err != nil {
+		return err
+	}
+	for _, classId := range current {
+		if slices.Contains(classIds, classId) {
+			continue
+		}
+		_, err := tx.Exec("DELETE FROM classes_teachers WHERE teacher_id = ? AND class_id = ?", teacherId, classId)
+		if err != nil {
+			return err
+		}
+	}
+	for _, classId := range classIds {
+		_, err := tx.Exec("INSERT OR IGNORE INTO classes_teachers (teacher_id, class_id, role) VALUES(?, ?, ?)", teacherId, classId, DEFAULT_TEACHER_ROLE)
+		if err != nil {
+			return err
+		}
+	}
+	return pruneTeacherSubjects(tx, teacherId)
+}
+
+func unlinkTeacher(tx *sql.Tx, teacherId any, classId any) error {
+	link, err := loadClassTeacher(tx, teacherId, classId)
+	if err != nil {
+		return err
+	}
+	if err := deleteRow(tx, "classes_teachers", link.Id); err != nil {
+		return err
+	}
+	return pruneTeacherSubjects(tx, link.Teacher.Id)
+}
+
+// The teacher and class ids of /teachers/{id}/classes/{classId}.
+func teacherClassPath(r *http.Request) (int64, int64, error) {
+	teacherId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, 0, invalid("teacher id %q is not an integer", r.PathValue("id"))
+	}
+	classId, err := strconv.ParseInt(r.PathValue("classId"), 10, 64)
+	if err != nil {
+		return 0, 0, invalid("class id %q is not an integer", r.PathValue("classId"))
+	}
+	return teacherId, classId, nil
+}
+
+// v1
+
+func getClassTeachers(w http.ResponseWriter, r *http.Request) {
+	links, err := queryClassTeachers(DB, "SELECT * FROM classes_teachers WHERE class_id = ? ORDER BY id", r.PathValue("id"))
+	if errorCheck(&w, err, 500) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(links)
+	return
+}
+
+func linkTeacherToClass(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	teacherId, classId, err := teacherClassPath(r)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	role := r.Form.Get("role")
+	if role == "" {
+		role = DEFAULT_TEACHER_ROLE
+	}
+	err = validateClassTeacher(DB, teacherId, classId, role)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	result, err := DB.Exec("INSERT INTO classes_teachers (teacher_id, class_id, role) VALUES(?, ?, ?)", teacherId, classId, role)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	id, err := result.LastInsertId()
+	if errorCheck(&w, err, 500) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(id)
+	return
+}
+
+func unlinkTeacherFromClass(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		return unlinkTeacher(tx, r.PathValue("id"), r.PathValue("classId"))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		errorCheck(&w, errors.New("the teacher isn't linked to the class"), http.StatusNotFound)
+		return
+	}
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	return
+}
+
+// v2
+
+func getClassTeachersV2(w http.ResponseWriter, r *http.Request) {
+	class, err := loadClass(DB, r.PathValue("id"))
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	listV2(w, r, queryClassTeachers, v2.FromClassTeacher, "SELECT * FROM classes_teachers WHERE class_id = ?", class.Id)
+}
+
+func linkTeacherToClassV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.ClassTeacherInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	if input.Role == "" {
+		input.Role = DEFAULT_TEACHER_ROLE
+	}
+
+	var link ClassTeacher
+	err = withTx(func(tx *sql.Tx) error {
+		teacherId, classId, err := teacherClassPath(r)
+		if err != nil {
+			return err
+		}
+		err = validateClassTeacher(tx, teacherId, classId, input.Role)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO classes_teachers (teacher_id, class_id, role) VALUES(?, ?, ?)", teacherId, classId, input.Role)
+		if err != nil {
+			return err
+		}
+		link, err = loadClassTeacher(tx, teacherId, classId)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/teachers/"+strconv.FormatInt(link.Teacher.Id, 10)+"/classes/"+strconv.FormatInt(link.Class, 10), v2.FromClassTeacher(link))
+}
+
+func getTeacherClassV2(w http.ResponseWriter, r *http.Request) {
+	link, err := loadClassTeacher(DB, r.PathValue("id"), r.PathValue("classId"))
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.ClassTeacher]{Data: v2.FromClassTeacher(link)})
+}
+
+// Changes the role of a teacher in a class.
+func updateTeacherClassV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.ClassTeacherInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var link ClassTeacher
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		link, err = loadClassTeacher(tx, r.PathValue("id"), r.PathValue("classId"))
+		if err != nil {
+			return err
+		}
+		if input.Role == "" {
+			input.Role = DEFAULT_TEACHER_ROLE
+		}
+		if err := validateTeacherRole(input.Role); err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE classes_teachers SET role = ? WHERE id = ?", input.Role, link.Id)
+		link.Role = input.Role
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.ClassTeacher]{Data: v2.FromClassTeacher(link)})
+}
+
+func unlinkTeacherFromClassV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		return unlinkTeacher(tx, r.PathValue("id"), r.PathValue("classId"))
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,623 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// Walks every page of it into a slice.
+func collect[T any](ctx context.Context, it *Iterator[T]) ([]T, error) {
+	results := []T{}
+	for it.Next(ctx) {
+		results = append(results, it.Value())
+	}
+	return results, it.Err()
+}
+
+func get[T any](c *Client, ctx context.Context, path string) (T, error) {
+	return data[T](c, ctx, request{method: "GET", path: path})
+}
+
+func create[T any](c *Client, ctx context.Context, path string, input any) (T, error) {
+	return data[T](c, ctx, request{method: "POST", path: path, json: input})
+}
+
+func update[T any](c *Client, ctx context.Context, path string, patch any) (T, error) {
+	return data[T](c, ctx, request{method: "PATCH", path: path, json: patch})
+}
+
+func (c *Client) delete(ctx context.Context, path string) error {
+	return c.do(ctx, request{method: "DELETE", path: path}, nil)
+}
+
+// Status
+
+// Succeeds when the server answers /status.
+func (c *Client) Status(ctx context.Context) error {
+	return c.do(ctx, request{method: "GET", path: "/status"}, nil)
+}
+
+// A failing check comes back as an *APIError with status 503.
+func (c *Client) Healthz(ctx context.Context, verbose bool) (HealthReport, error) {
+	var report HealthReport
+	err := c.do(ctx, request{method: "GET", path: "/healthz", query: url.Values{"verbose": {strconv.FormatBool(verbose)}}}, &report)
+	return report, err
+}
+
+func (c *Client) Readyz(ctx context.Context, verbose bool) (HealthReport, error) {
+	var report HealthReport
+	err := c.do(ctx, request{method: "GET", path: "/readyz", query: url.Values{"verbose": {strconv.FormatBool(verbose)}}}, &report)
+	return report, err
+}
+
+// Metrics in the Prometheus text format.
+func (c *Client) Metrics(ctx context.Context) (string, error) {
+	data, err := c.raw(ctx, "/metrics")
+	return string(data), err
+}
+
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	return c.raw(ctx, "/openapi.json")
+}
+
+func (c *Client) Docs(ctx context.Context) ([]byte, error) {
+	return c.raw(ctx, "/docs")
+}
+
+func (c *Client) raw(ctx context.Context, path string) ([]byte, error) {
+	resp, err := c.send(ctx, request{method: "GET", path: path})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Students
+
+func (c *Client) ListStudents(ctx context.Context) ([]Student, error) {
+	return collect(ctx, c.IterStudents(0))
+}
+
+func (c *Client) IterStudents(pageSize int) *Iterator[Student] {
+	return newIterator[Student](c, "/api/v2/students", nil, pageSize)
+}
+
+func (c *Client) CreateStudent(ctx context.Context, student StudentInput) (Student, error) {
+	return create[Student](c, ctx, "/api/v2/students", student)
+}
+
+func (c *Client) GetStudent(ctx context.Context, studentId int64) (Student, error) {
+	return get[Student](c, ctx, "/api/v2/students/"+id(studentId))
+}
+
+func (c *Client) UpdateStudent(ctx context.Context, studentId int64, patch StudentPatch) (Student, error) {
+	return update[Student](c, ctx, "/api/v2/students/"+id(studentId), patch)
+}
+
+func (c *Client) DeleteStudent(ctx context.Context, studentId int64) error {
+	return c.delete(ctx, "/api/v2/students/"+id(studentId))
+}
+
+// Students of the classes of a term's or year's school year.
+func (c *Client) ListStudentsIn(ctx context.Context, period Period) ([]Student, error) {
+	return collect(ctx, c.IterStudentsIn(period, 0))
+}
+
+func (c *Client) IterStudentsIn(period Period, pageSize int) *Iterator[Student] {
+	return newIterator[Student](c, "/api/v2/students", period.query(), pageSize)
+}
+
+func (c *Client) ListStudentsByClass(ctx context.Context, classId int64) ([]Student, error) {
+	return collect(ctx, c.IterStudentsByClass(classId, 0))
+}
+
+func (c *Client) IterStudentsByClass(classId int64, pageSize int) *Iterator[Student] {
+	return newIterator[Student](c, "/api/v2/students/class/"+id(classId), nil, pageSize)
+}
+
+// The students enrolled in a class on day, YYYY-MM-DD.
+func (c *Client) ListStudentsByClassOn(ctx context.Context, classId int64, day string) ([]Student, error) {
+	return collect(ctx, c.IterStudentsByClassOn(classId, day, 0))
+}
+
+func (c *Client) IterStudentsByClassOn(classId int64, day string, pageSize int) *Iterator[Student] {
+	return newIterator[Student](c, "/api/v2/students/class/"+id(classId), url.Values{"as_of": {day}}, pageSize)
+}
+
+// The classes a student was enrolled in, oldest first.
+func (c *Client) ListEnrollments(ctx context.Context, studentId int64) ([]Enrollment, error) {
+	return collect(ctx, c.IterEnrollments(studentId, 0))
+}
+
+func (c *Client) IterEnrollments(studentId int64, pageSize int) *Iterator[Enrollment] {
+	return newIterator[Enrollment](c, "/api/v2/students/enrollments/"+id(studentId), nil, pageSize)
+}
+
+// Classes
+
+// A zero period lists every class.
+func (c *Client) ListClasses(ctx context.Context, period Period) ([]Class, error) {
+	return collect(ctx, c.IterClasses(period, 0))
+}
+
+func (c *Client) IterClasses(period Period, pageSize int) *Iterator[Class] {
+	return newIterator[Class](c, "/api/v2/classes", period.query(), pageSize)
+}
+
+func (c *Client) CreateClass(ctx context.Context, class ClassInput) (Class, error) {
+	return create[Class](c, ctx, "/api/v2/classes", class)
+}
+
+func (c *Client) GetClass(ctx context.Context, classId int64) (Class, error) {
+	return get[Class](c, ctx, "/api/v2/classes/"+id(classId))
+}
+
+func (c *Client) UpdateClass(ctx context.Context, classId int64, patch ClassPatch) (Class, error) {
+	return update[Class](c, ctx, "/api/v2/classes/"+id(classId), patch)
+}
+
+// School years
+
+func (c *Client) ListSchoolYears(ctx context.Context) ([]SchoolYear, error) {
+	return collect(ctx, c.IterSchoolYears(0))
+}
+
+func (c *Client) IterSchoolYears(pageSize int) *Iterator[SchoolYear] {
+	return newIterator[SchoolYear](c, "/api/v2/years", nil, pageSize)
+}
+
+func (c *Client) CreateSchoolYear(ctx context.Context, year SchoolYearInput) (SchoolYear, error) {
+	return create[SchoolYear](c, ctx, "/api/v2/years", year)
+}
+
+func (c *Client) GetSchoolYear(ctx context.Context, yearId int64) (SchoolYear, error) {
+	return get[SchoolYear](c, ctx, "/api/v2/years/"+id(yearId))
+}
+
+func (c *Client) UpdateSchoolYear(ctx context.Context, yearId int64, patch SchoolYearPatch) (SchoolYear, error) {
+	return update[SchoolYear](c, ctx, "/api/v2/years/"+id(yearId), patch)
+}
+
+func (c *Client) DeleteSchoolYear(ctx context.Context, yearId int64) error {
+	return c.delete(ctx, "/api/v2/years/"+id(yearId))
+}
+
+// Terms
+
+// A zero yearId lists the terms of every year.
+func (c *Client) ListTerms(ctx context.Context, yearId int64) ([]Term, error) {
+	return collect(ctx, c.IterTerms(yearId, 0))
+}
+
+func (c *Client) IterTerms(yearId int64, pageSize int) *Iterator[Term] {
+	var query url.Values
+	if yearId != 0 {
+		query = url.Values{"year_id": {id(yearId)}}
+	}
+	return newIterator[Term](c, "/api/v2/terms", query, pageSize)
+}
+
+func (c *Client) CreateTerm(ctx context.Context, term TermInput) (Term, error) {
+	return create[Term](c, ctx, "/api/v2/terms", term)
+}
+
+func (c *Client) GetTerm(ctx context.Context, termId int64) (Term, error) {
+	return get[Term](c, ctx, "/api/v2/terms/"+id(termId))
+}
+
+func (c *Client) UpdateTerm(ctx context.Context, termId int64, patch TermPatch) (Term, error) {
+	return update[Term](c, ctx, "/api/v2/terms/"+id(termId), patch)
+}
+
+func (c *Client) DeleteTerm(ctx context.Context, termId int64) error {
+	return c.delete(ctx, "/api/v2/terms/"+id(termId))
+}
+
+// Rollovers
+
+func (c *Client) ListRollovers(ctx context.Context) ([]Rollover, error) {
+	return collect(ctx, c.IterRollovers(0))
+}
+
+func (c *Client) IterRollovers(pageSize int) *Iterator[Rollover] {
+	return newIterator[Rollover](c, "/api/v2/rollovers", nil, pageSize)
+}
+
+// Rolls a school year over into the next. With input.DryRun set nothing
+// changes and the result has a nil Id.
+func (c *Client) RollOver(ctx context.Context, input RolloverInput) (Rollover, error) {
+	return create[Rollover](c, ctx, "/api/v2/rollovers", input)
+}
+
+func (c *Client) GetRollover(ctx context.Context, rolloverId int64) (Rollover, error) {
+	return get[Rollover](c, ctx, "/api/v2/rollovers/"+id(rolloverId))
+}
+
+func (c *Client) UndoRollover(ctx context.Context, rolloverId int64) (Rollover, error) {
+	return data[Rollover](c, ctx, request{method: "POST", path: "/api/v2/rollovers/undo/" + id(rolloverId)})
+}
+
+// Teachers
+
+func (c *Client) ListTeachers(ctx context.Context) ([]Teacher, error) {
+	return collect(ctx, c.IterTeachers(0))
+}
+
+func (c *Client) IterTeachers(pageSize int) *Iterator[Teacher] {
+	return newIterator[Teacher](c, "/api/v2/teachers", nil, pageSize)
+}
+
+// Teachers of the classes of a term's or year's school year.
+func (c *Client) ListTeachersIn(ctx context.Context, period Period) ([]Teacher, error) {
+	return collect(ctx, c.IterTeachersIn(period, 0))
+}
+
+func (c *Client) IterTeachersIn(period Period, pageSize int) *Iterator[Teacher] {
+	return newIterator[Teacher](c, "/api/v2/teachers", period.query(), pageSize)
+}
+
+func (c *Client) CreateTeacher(ctx context.Context, teacher TeacherInput) (Teacher, error) {
+	return create[Teacher](c, ctx, "/api/v2/teachers", teacher)
+}
+
+func (c *Client) GetTeacher(ctx context.Context, teacherId int64) (Teacher, error) {
+	return get[Teacher](c, ctx, "/api/v2/teachers/"+id(teacherId))
+}
+
+func (c *Client) UpdateTeacher(ctx context.Context, teacherId int64, patch TeacherPatch) (Teacher, error) {
+	return update[Teacher](c, ctx, "/api/v2/teachers/"+id(teacherId), patch)
+}
+
+func (c *Client) DeleteTeacher(ctx context.Context, teacherId int64) error {
+	return c.delete(ctx, "/api/v2/teachers/"+id(teacherId))
+}
+
+// Teachers of a class with their role, lead or support.
+func (c *Client) ListClassTeachers(ctx context.Context, classId int64) ([]ClassTeacher, error) {
+	return collect(ctx, c.IterClassTeachers(classId, 0))
+}
+
+func (c *Client) IterClassTeachers(classId int64, pageSize int) *Iterator[ClassTeacher] {
+	return newIterator[ClassTeacher](c, "/api/v2/classes/"+id(classId)+"/teachers", nil, pageSize)
+}
+
+func (c *Client) GetTeacherClass(ctx context.Context, teacherId int64, classId int64) (ClassTeacher, error) {
+	return get[ClassTeacher](c, ctx, "/api/v2/teachers/"+id(teacherId)+"/classes/"+id(classId))
+}
+
+// Links a teacher to a class, an empty role is lead.
+func (c *Client) LinkTeacher(ctx context.Context, teacherId int64, classId int64, role string) (ClassTeacher, error) {
+	return create[ClassTeacher](c, ctx, "/api/v2/teachers/"+id(teacherId)+"/classes/"+id(classId), ClassTeacherInput{Role: role})
+}
+
+func (c *Client) SetTeacherRole(ctx context.Context, teacherId int64, classId int64, role string) (ClassTeacher, error) {
+	return update[ClassTeacher](c, ctx, "/api/v2/teachers/"+id(teacherId)+"/classes/"+id(classId), ClassTeacherInput{Role: role})
+}
+
+func (c *Client) UnlinkTeacher(ctx context.Context, teacherId int64, classId int64) error {
+	return c.delete(ctx, "/api/v2/teachers/"+id(teacherId)+"/classes/"+id(classId))
+}
+
+// Remarks
+
+func (c *Client) ListRemarks(ctx context.Context, includeArchived bool) ([]Remark, error) {
+	return collect(ctx, c.IterRemarks(includeArchived, 0))
+}
+
+func (c *Client) IterRemarks(includeArchived bool, pageSize int) *Iterator[Remark] {
+	return newIterator[Remark](c, "/api/v2/remarks", url.Values{"archived": {strconv.FormatBool(includeArchived)}}, pageSize)
+}
+
+func (c *Client) CreateRemark(ctx context.Context, remark RemarkInput) (Remark, error) {
+	return create[Remark](c, ctx, "/api/v2/remarks", remark)
+}
+
+// Uploads a rubric; format is "csv" or "json".
+func (c *Client) ImportRemarks(ctx context.Context, format string, rubric io.Reader, options ImportOptions) (RemarkImportReport, error) {
+	contentType := "text/csv"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	query := url.Values{
+		"format":  {format},
+		"dry_run": {strconv.FormatBool(options.DryRun)},
+		"archive": {strconv.FormatBool(options.Archive)},
+	}
+	var report RemarkImportReport
+	err := c.do(ctx, request{method: "POST", path: "/api/remarks/import", query: query, body: rubric, contentType: contentType}, &report)
+	return report, err
+}
+
+func (c *Client) GetRemark(ctx context.Context, remarkId int64) (Remark, error) {
+	return get[Remark](c, ctx, "/api/v2/remarks/"+id(remarkId))
+}
+
+func (c *Client) UpdateRemark(ctx context.Context, remarkId int64, patch RemarkPatch) (Remark, error) {
+	return update[Remark](c, ctx, "/api/v2/remarks/"+id(remarkId), patch)
+}
+
+func (c *Client) DeleteRemark(ctx context.Context, remarkId int64) error {
+	return c.delete(ctx, "/api/v2/remarks/"+id(remarkId))
+}
+
+// Skills
+
+func (c *Client) ListSkills(ctx context.Context) ([]Skill, error) {
+	return collect(ctx, c.IterSkills(0))
+}
+
+func (c *Client) IterSkills(pageSize int) *Iterator[Skill] {
+	return newIterator[Skill](c, "/api/v2/skills", nil, pageSize)
+}
+
+func (c *Client) RenameSkill(ctx context.Context, skillId int64, name string) (Skill, error) {
+	return update[Skill](c, ctx, "/api/v2/skills/"+id(skillId), SkillPatch{Name: &name})
+}
+
+func (c *Client) UpdateSkill(ctx context.Context, skillId int64, patch SkillPatch) (Skill, error) {
+	return update[Skill](c, ctx, "/api/v2/skills/"+id(skillId), patch)
+}
+
+// Competency framework
+
+// A zero parentId lists every node, ListFrameworkRoots only the roots.
+func (c *Client) ListFrameworkNodes(ctx context.Context, parentId int64) ([]FrameworkNode, error) {
+	return collect(ctx, c.IterFrameworkNodes(parentId, 0))
+}
+
+func (c *Client) IterFrameworkNodes(parentId int64, pageSize int) *Iterator[FrameworkNode] {
+	var query url.Values
+	if parentId != 0 {
+		query = url.Values{"parent_id": {id(parentId)}}
+	}
+	return newIterator[FrameworkNode](c, "/api/v2/framework/nodes", query, pageSize)
+}
+
+func (c *Client) ListFrameworkRoots(ctx context.Context) ([]FrameworkNode, error) {
+	return collect(ctx, newIterator[FrameworkNode](c, "/api/v2/framework/nodes", url.Values{"parent_id": {"0"}}, 0))
+}
+
+func (c *Client) CreateFrameworkNode(ctx context.Context, node FrameworkNodeInput) (FrameworkNode, error) {
+	return create[FrameworkNode](c, ctx, "/api/v2/framework/nodes", node)
+}
+
+func (c *Client) GetFrameworkNode(ctx context.Context, nodeId int64) (FrameworkNode, error) {
+	return get[FrameworkNode](c, ctx, "/api/v2/framework/nodes/"+id(nodeId))
+}
+
+func (c *Client) UpdateFrameworkNode(ctx context.Context, nodeId int64, patch FrameworkNodePatch) (FrameworkNode, error) {
+	return update[FrameworkNode](c, ctx, "/api/v2/framework/nodes/"+id(nodeId), patch)
+}
+
+func (c *Client) DeleteFrameworkNode(ctx context.Context, nodeId int64) error {
+	return c.delete(ctx, "/api/v2/framework/nodes/"+id(nodeId))
+}
+
+// Subjects
+
+func (c *Client) ListSubjects(ctx context.Context) ([]Subject, error) {
+	return collect(ctx, c.IterSubjects(0))
+}
+
+func (c *Client) IterSubjects(pageSize int) *Iterator[Subject] {
+	return newIterator[Subject](c, "/api/v2/subjects", nil, pageSize)
+}
+
+func (c *Client) CreateSubject(ctx context.Context, subject SubjectInput) (Subject, error) {
+	return create[Subject](c, ctx, "/api/v2/subjects", subject)
+}
+
+func (c *Client) GetSubject(ctx context.Context, subjectId int64) (Subject, error) {
+	return get[Subject](c, ctx, "/api/v2/subjects/"+id(subjectId))
+}
+
+func (c *Client) UpdateSubject(ctx context.Context, subjectId int64, patch SubjectPatch) (Subject, error) {
+	return update[Subject](c, ctx, "/api/v2/subjects/"+id(subjectId), patch)
+}
+
+func (c *Client) DeleteSubject(ctx context.Context, subjectId int64) error {
+	return c.delete(ctx, "/api/v2/subjects/"+id(subjectId))
+}
+
+func (c *Client) ListSubjectAssignments(ctx context.Context, filter SubjectAssignmentFilter) ([]SubjectAssignment, error) {
+	return collect(ctx, c.IterSubjectAssignments(filter, 0))
+}
+
+func (c *Client) IterSubjectAssignments(filter SubjectAssignmentFilter, pageSize int) *Iterator[SubjectAssignment] {
+	return newIterator[SubjectAssignment](c, "/api/v2/subjects/assignments", filter.query(), pageSize)
+}
+
+// Assigns a teacher a subject in a class, linking them to the class too.
+func (c *Client) AssignSubject(ctx context.Context, assignment SubjectAssignmentInput) (SubjectAssignment, error) {
+	return create[SubjectAssignment](c, ctx, "/api/v2/subjects/assignments", assignment)
+}
+
+func (c *Client) GetSubjectAssignment(ctx context.Context, assignmentId int64) (SubjectAssignment, error) {
+	return get[SubjectAssignment](c, ctx, "/api/v2/subjects/assignments/"+id(assignmentId))
+}
+
+func (c *Client) DeleteSubjectAssignment(ctx context.Context, assignmentId int64) error {
+	return c.delete(ctx, "/api/v2/subjects/assignments/"+id(assignmentId))
+}
+
+// Observations
+
+func (c *Client) ListObservations(ctx context.Context) ([]Observation, error) {
+	return collect(ctx, c.IterObservations(0))
+}
+
+func (c *Client) IterObservations(pageSize int) *Iterator[Observation] {
+	return newIterator[Observation](c, "/api/v2/observations", nil, pageSize)
+}
+
+func (c *Client) CreateObservation(ctx context.Context, observation ObservationInput) (Observation, error) {
+	return create[Observation](c, ctx, "/api/v2/observations", observation)
+}
+
+// Records batch in one transaction. With partial set, invalid entries are
+// skipped and listed in the result instead of failing the whole batch.
+func (c *Client) RecordObservations(ctx context.Context, batch ObservationBatch, partial bool) (ObservationBatchResult, error) {
+	return data[ObservationBatchResult](c, ctx, request{method: "POST", path: "/api/v2/observations/batch", query: url.Values{"partial": {strconv.FormatBool(partial)}}, json: batch})
+}
+
+func (c *Client) GetObservation(ctx context.Context, observationId int64) (Observation, error) {
+	return get[Observation](c, ctx, "/api/v2/observations/"+id(observationId))
+}
+
+func (c *Client) UpdateObservation(ctx context.Context, observationId int64, patch ObservationPatch) (Observation, error) {
+	return update[Observation](c, ctx, "/api/v2/observations/"+id(observationId), patch)
+}
+
+func (c *Client) DeleteObservation(ctx context.Context, observationId int64) error {
+	return c.delete(ctx, "/api/v2/observations/"+id(observationId))
+}
+
+// Returns every revision of an observation, oldest first.
+func (c *Client) ObservationHistory(ctx context.Context, observationId int64) ([]ObservationRevision, error) {
+	return collect(ctx, c.IterObservationHistory(observationId, 0))
+}
+
+func (c *Client) IterObservationHistory(observationId int64, pageSize int) *Iterator[ObservationRevision] {
+	return newIterator[ObservationRevision](c, "/api/v2/observations/history/"+id(observationId), nil, pageSize)
+}
+
+// Restores the observation of a revision to its state then. The revert is
+// a revision too, so it can be undone the same way.
+func (c *Client) RevertObservation(ctx context.Context, revisionId int64) (Observation, error) {
+	return data[Observation](c, ctx, request{method: "POST", path: "/api/v2/observations/revert/" + id(revisionId)})
+}
+
+func (c *Client) ListObservationsOnStudent(ctx context.Context, studentId int64) ([]Observation, error) {
+	return collect(ctx, c.IterObservationsOnStudent(studentId, 0))
+}
+
+func (c *Client) IterObservationsOnStudent(studentId int64, pageSize int) *Iterator[Observation] {
+	return newIterator[Observation](c, "/api/v2/observations/student/"+id(studentId), nil, pageSize)
+}
+
+func (c *Client) ListObservationsByTeacher(ctx context.Context, teacherId int64) ([]Observation, error) {
+	return collect(ctx, c.IterObservationsByTeacher(teacherId, 0))
+}
+
+func (c *Client) IterObservationsByTeacher(teacherId int64, pageSize int) *Iterator[Observation] {
+	return newIterator[Observation](c, "/api/v2/observations/teacher/"+id(teacherId), nil, pageSize)
+}
+
+func (c *Client) ListObservationsByTeacherOnStudent(ctx context.Context, teacherId int64, studentId int64) ([]Observation, error) {
+	return collect(ctx, c.IterObservationsByTeacherOnStudent(teacherId, studentId, 0))
+}
+
+func (c *Client) IterObservationsByTeacherOnStudent(teacherId int64, studentId int64, pageSize int) *Iterator[Observation] {
+	return newIterator[Observation](c, "/api/v2/observations/teacher/"+id(teacherId)+"/student/"+id(studentId), nil, pageSize)
+}
+
+func (c *Client) ListObservationsWhere(ctx context.Context, filter ObservationFilter) ([]Observation, error) {
+	return collect(ctx, c.IterObservationsWhere(filter, 0))
+}
+
+func (c *Client) IterObservationsWhere(filter ObservationFilter, pageSize int) *Iterator[Observation] {
+	return newIterator[Observation](c, "/api/v2/observations", filter.query(), pageSize)
+}
+
+// Evidence
+
+func (c *Client) ListEvidence(ctx context.Context, observationId int64) ([]Evidence, error) {
+	return collect(ctx, c.IterEvidence(observationId, 0))
+}
+
+func (c *Client) IterEvidence(observationId int64, pageSize int) *Iterator[Evidence] {
+	return newIterator[Evidence](c, "/api/v2/evidence/observation/"+id(observationId), nil, pageSize)
+}
+
+// Attaches the content of file to an observation. The server checks the
+// media type from the content itself. Uploads are not retried.
+func (c *Client) UploadEvidence(ctx context.Context, observationId int64, filename string, file io.Reader) (Evidence, error) {
+	return c.UploadEvidenceChecked(ctx, observationId, filename, file, "")
+}
+
+// UploadEvidence with the hex SHA-256 of the file, which the server checks
+// to reject uploads damaged on the way. An empty sha256 skips the check.
+func (c *Client) UploadEvidenceChecked(ctx context.Context, observationId int64, filename string, file io.Reader, sha256 string) (Evidence, error) {
+	query := url.Values{"filename": {filename}}
+	if sha256 != "" {
+		query.Set("sha256", sha256)
+	}
+	return data[Evidence](c, ctx, request{method: "POST", path: "/api/v2/evidence/observation/" + id(observationId), query: query, body: file, contentType: "application/octet-stream"})
+}
+
+func (c *Client) GetEvidence(ctx context.Context, evidenceId int64) (Evidence, error) {
+	return get[Evidence](c, ctx, "/api/v2/evidence/"+id(evidenceId))
+}
+
+// Streams the content of an evidence file into w.
+func (c *Client) DownloadEvidence(ctx context.Context, evidenceId int64, w io.Writer) error {
+	return c.download(ctx, "/api/v2/evidence/content/"+id(evidenceId), w)
+}
+
+// Streams the JPEG thumbnail of an image evidence file into w. Files
+// without a ThumbnailUrl have none.
+func (c *Client) DownloadThumbnail(ctx context.Context, evidenceId int64, w io.Writer) error {
+	return c.download(ctx, "/api/v2/evidence/thumbnail/"+id(evidenceId), w)
+}
+
+func (c *Client) download(ctx context.Context, path string, w io.Writer) error {
+	resp, err := c.send(ctx, request{method: "GET", path: path})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *Client) DeleteEvidence(ctx context.Context, evidenceId int64) error {
+	return c.delete(ctx, "/api/v2/evidence/"+id(evidenceId))
+}
+
+// Outcomes
+
+// The outcome scale, lowest rank first.
+func (c *Client) ListOutcomes(ctx context.Context) ([]Outcome, error) {
+	return get[[]Outcome](c, ctx, "/api/v2/outcomes")
+}
+
+// Replaces the outcome scale, outcomes still used by observations must stay.
+func (c *Client) SetOutcomes(ctx context.Context, outcomes []Outcome) ([]Outcome, error) {
+	return data[[]Outcome](c, ctx, request{method: "PUT", path: "/api/v2/outcomes", json: outcomes})
+}
+
+// Reports
+
+func (c *Client) StudentReport(ctx context.Context, studentId int64) (StudentReport, error) {
+	return get[StudentReport](c, ctx, "/api/v2/reports/students/"+id(studentId))
+}
+
+// StudentReport over the observations of a term or year only.
+func (c *Client) StudentReportIn(ctx context.Context, studentId int64, period Period) (StudentReport, error) {
+	return data[StudentReport](c, ctx, request{method: "GET", path: "/api/v2/reports/students/" + id(studentId), query: period.query()})
+}
+
+// StudentReport over the skills under a framework node, with the node and
+// those below it summed up.
+func (c *Client) StudentReportOn(ctx context.Context, studentId int64, nodeId int64, period Period) (StudentReport, error) {
+	query := period.query()
+	query.Set("node_id", id(nodeId))
+	return data[StudentReport](c, ctx, request{method: "GET", path: "/api/v2/reports/students/" + id(studentId), query: query})
+}
+
+// Admin
+
+// Streams a database snapshot into w.
+func (c *Client) Backup(ctx context.Context, w io.Writer) error {
+	resp, err := c.send(ctx, request{method: "GET", path: "/api/admin/backup"})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,236 @@
+// Package client is the Go client for the Svalutation API. It talks to the
+// /api/v2 routes and decodes responses into the same entities/v2 types the
+// server encodes them from.
+package client
+
+import (
+	"api/entities/v2"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	user       string
+	password   string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// Basic Auth credentials sent with every request.
+func WithCredentials(user string, password string) Option {
+	return func(c *Client) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// Client certificate for servers with tls_client_auth enabled. Replaces the
+// transport of the HTTP client.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *Client) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		c.httpClient.Transport = transport
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// How many times idempotent requests are retried after network errors,
+// 429 and 5xx responses, and the first delay, doubled on every attempt.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: time.Minute},
+		retries:    3,
+		backoff:    200 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// A non-2xx answer from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("svalutation: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("svalutation: %d %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	form        url.Values
+	json        any
+	body        io.Reader
+	contentType string
+}
+
+func retryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (c *Client) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	d := c.backoff << attempt
+	if d > c.maxBackoff || d <= 0 {
+		d = c.maxBackoff
+	}
+	// Full jitter keeps a fleet of clients from retrying in lockstep
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Sends req, retrying idempotent requests, and returns the successful
+// response for the caller to read and close.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	if len(req.query) > 0 {
+		u.RawQuery = req.query.Encode()
+	}
+
+	var payload []byte
+	if req.json != nil {
+		var err error
+		payload, err = json.Marshal(req.json)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Raw bodies can't be replayed, so only form and JSON requests are retried
+	attempts := 1
+	if retryable(req.method) && req.body == nil {
+		attempts += c.retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, contentType := req.body, req.contentType
+		if req.form != nil {
+			body, contentType = strings.NewReader(req.form.Encode()), "application/x-www-form-urlencoded"
+		}
+		if payload != nil {
+			body, contentType = bytes.NewReader(payload), "application/json"
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+		if c.user != "" {
+			httpReq.SetBasicAuth(c.user, c.password)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			apiErr := readError(resp)
+			if !apiErr.Temporary() {
+				return nil, apiErr
+			}
+			err = apiErr
+		}
+		if attempt+1 >= attempts || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(c.delay(attempt, resp)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// v2 routes fail with a JSON error object, the others with plain text.
+func readError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message)), RequestID: resp.Header.Get("X-Request-ID")}
+	var body v2.Error
+	if json.Unmarshal(message, &body) == nil && body.Error.Message != "" {
+		apiErr.Message = body.Error.Message
+	}
+	return apiErr
+}
+
+// Sends req and decodes a JSON answer into out, unless out is nil.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Sends req to a v2 route and unwraps the resource from its envelope.
+func data[T any](c *Client, ctx context.Context, req request) (T, error) {
+	var envelope v2.Data[T]
+	err := c.do(ctx, req, &envelope)
+	return envelope.Data, err
+}
+
+func id(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
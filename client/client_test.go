@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Answers with the given statuses in turn, then with an empty student list.
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-ID", "req-1")
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(statuses[n-1])
+			fmt.Fprintf(w, `{"error":{"status":%d,"message":"busy"}}`, statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[],"meta":{"limit":500,"offset":0,"total":0}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestRetries(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int32
+		fails    bool
+	}{
+		{"5xx then success", []int{503, 500}, 3, 3, false},
+		{"429 then success", []int{429}, 3, 2, false},
+		{"retries run out", []int{503, 503, 503}, 2, 3, true},
+		{"4xx isn't retried", []int{404}, 3, 1, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, calls := flakyServer(t, test.statuses...)
+			c, err := New(server.URL, WithRetries(test.retries, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.ListStudents(context.Background())
+			if (err != nil) != test.fails {
+				t.Errorf("got error %v", err)
+			}
+			if calls.Load() != test.calls {
+				t.Errorf("got %d calls, want %d", calls.Load(), test.calls)
+			}
+		})
+	}
+}
+
+func TestPostIsNotRetried(t *testing.T) {
+	server, calls := flakyServer(t, 503)
+	c, err := New(server.URL, WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CreateStudent(context.Background(), StudentInput{Name: "Ada"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Temporary() {
+		t.Errorf("got %v, want a temporary APIError", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d calls, POST must not be retried", calls.Load())
+	}
+}
+
+func TestAPIErrorDecoding(t *testing.T) {
+	server, _ := flakyServer(t, 503)
+	c, err := New(server.URL, WithRetries(0, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ListStudents(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != 503 || apiErr.Message != "busy" || apiErr.RequestID != "req-1" {
+		t.Errorf("got %+v", apiErr)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Authentication failed", http.StatusUnauthorized)
+	}))
+	defer plain.Close()
+	c, err = New(plain.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ListStudents(context.Background())
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || apiErr.Message != "Authentication failed" {
+		t.Errorf("got %v, want the plain text message", err)
+	}
+}
+
+func TestCancelStopsBackoff(t *testing.T) {
+	server, calls := flakyServer(t, 503, 503, 503)
+	c, err := New(server.URL, WithRetries(3, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.ListStudents(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d calls during the backoff", calls.Load())
+	}
+}
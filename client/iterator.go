@@ -0,0 +1,74 @@
+package client
+
+import (
+	"api/entities/v2"
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// The largest page the server hands out.
+const DefaultPageSize = 500
+
+// Walks a v2 list endpoint page by page using ?limit= and ?offset=:
+//
+//	it := c.IterStudents(0)
+//	for it.Next(ctx) {
+//		student := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+type Iterator[T any] struct {
+	client   *Client
+	path     string
+	query    url.Values
+	pageSize int
+
+	offset int
+	page   []T
+	index  int
+	last   bool
+	err    error
+}
+
+func newIterator[T any](c *Client, path string, query url.Values, pageSize int) *Iterator[T] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	return &Iterator[T]{client: c, path: path, query: query, pageSize: pageSize, index: -1}
+}
+
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	it.index++
+	if it.index < len(it.page) {
+		return true
+	}
+	if it.last {
+		return false
+	}
+
+	it.query.Set("limit", strconv.Itoa(it.pageSize))
+	it.query.Set("offset", strconv.Itoa(it.offset))
+	var page v2.Page[T]
+	it.err = it.client.do(ctx, request{method: "GET", path: it.path, query: it.query}, &page)
+	if it.err != nil {
+		return false
+	}
+	it.page, it.index = page.Data, 0
+	it.offset += len(page.Data)
+	it.last = len(page.Data) == 0 || int64(it.offset) >= page.Meta.Total
+	return len(page.Data) > 0
+}
+
+func (it *Iterator[T]) Value() T {
+	return it.page[it.index]
+}
+
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"api/entities"
+	"api/entities/v2"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type Student = v2.Student
+type Teacher = v2.Teacher
+type ClassTeacher = v2.ClassTeacher
+type Remark = v2.Remark
+type Observation = v2.Observation
+type Class = v2.Class
+type Skill = v2.Skill
+type Outcome = v2.Outcome
+type Evidence = v2.Evidence
+type StudentReport = v2.StudentReport
+type SkillReport = v2.SkillReport
+type NodeReport = v2.NodeReport
+type OutcomeSummary = v2.OutcomeSummary
+type FrameworkNode = v2.FrameworkNode
+type ObservationRevision = v2.ObservationRevision
+type RevisionChange = v2.RevisionChange
+type SchoolYear = v2.SchoolYear
+type Term = v2.Term
+type Subject = v2.Subject
+type SubjectAssignment = v2.SubjectAssignment
+type Enrollment = v2.Enrollment
+type Rollover = v2.Rollover
+type RolloverClass = v2.RolloverClass
+type RolloverStudent = v2.RolloverStudent
+
+type StudentInput = v2.StudentInput
+type StudentPatch = v2.StudentPatch
+type TeacherInput = v2.TeacherInput
+type TeacherPatch = v2.TeacherPatch
+type ClassTeacherInput = v2.ClassTeacherInput
+type RemarkInput = v2.RemarkInput
+type RemarkPatch = v2.RemarkPatch
+type SkillPatch = v2.SkillPatch
+type FrameworkNodeInput = v2.FrameworkNodeInput
+type FrameworkNodePatch = v2.FrameworkNodePatch
+type SubjectInput = v2.SubjectInput
+type SubjectPatch = v2.SubjectPatch
+type SubjectAssignmentInput = v2.SubjectAssignmentInput
+type ClassInput = v2.ClassInput
+type ClassPatch = v2.ClassPatch
+type SchoolYearInput = v2.SchoolYearInput
+type SchoolYearPatch = v2.SchoolYearPatch
+type TermInput = v2.TermInput
+type TermPatch = v2.TermPatch
+type RolloverInput = v2.RolloverInput
+type RolloverClassInput = v2.RolloverClassInput
+type ObservationInput = v2.ObservationInput
+type ObservationPatch = v2.ObservationPatch
+type ObservationBatch = v2.ObservationBatch
+type ObservationBatchEntry = v2.ObservationBatchEntry
+type ObservationBatchResult = v2.ObservationBatchResult
+
+// Narrows lists and reports to a term or a school year, set one at most.
+type Period struct {
+	TermId int64
+	YearId int64
+}
+
+func (p Period) query() url.Values {
+	query := url.Values{}
+	if p.TermId != 0 {
+		query.Set("term_id", strconv.FormatInt(p.TermId, 10))
+	}
+	if p.YearId != 0 {
+		query.Set("year_id", strconv.FormatInt(p.YearId, 10))
+	}
+	return query
+}
+
+// Narrows subject assignment lists, zero fields don't filter.
+type SubjectAssignmentFilter struct {
+	TeacherId int64
+	ClassId   int64
+	SubjectId int64
+}
+
+func (f SubjectAssignmentFilter) query() url.Values {
+	query := url.Values{}
+	for param, value := range map[string]int64{"teacher_id": f.TeacherId, "class_id": f.ClassId, "subject_id": f.SubjectId} {
+		if value != 0 {
+			query.Set(param, strconv.FormatInt(value, 10))
+		}
+	}
+	return query
+}
+
+// Narrows observation lists, zero fields don't filter.
+type ObservationFilter struct {
+	Outcomes   []string
+	MinOutcome string
+	Achieved   *bool
+	TeacherId  int64
+	StudentId  int64
+	RemarkId   int64
+	SkillId    int64
+	NodeId     int64
+	Period
+}
+
+func (f ObservationFilter) query() url.Values {
+	query := f.Period.query()
+	if len(f.Outcomes) > 0 {
+		query.Set("outcome", strings.Join(f.Outcomes, ","))
+	}
+	if f.MinOutcome != "" {
+		query.Set("min_outcome", f.MinOutcome)
+	}
+	if f.Achieved != nil {
+		query.Set("achieved", strconv.FormatBool(*f.Achieved))
+	}
+	for param, value := range map[string]int64{"teacher_id": f.TeacherId, "student_id": f.StudentId, "remark_id": f.RemarkId, "skill_id": f.SkillId, "node_id": f.NodeId} {
+		if value != 0 {
+			query.Set(param, strconv.FormatInt(value, 10))
+		}
+	}
+	return query
+}
+
+type HealthCheck struct {
+	Status string
+	Detail string
+}
+
+type HealthReport struct {
+	Status string
+	Checks map[string]HealthCheck
+}
+
+// How one remark changed in an import.
+type RemarkChange struct {
+	Previous entities.Remark
+	Current  entities.Remark
+}
+
+// Rubric imports have no v2 route yet, so the report keeps the v1 shape.
+type RemarkImportReport struct {
+	DryRun        bool
+	SkillsCreated []entities.Skill
+	SkillsRenamed []entities.Skill
+	Created       []entities.Remark
+	Updated       []RemarkChange
+	Unchanged     int
+	Archived      []entities.Remark
+}
+
+type ImportOptions struct {
+	DryRun  bool
+	Archive bool
+}
+
+// Pointer fields in patches are easier to fill with Ptr:
+//
+//	c.UpdateStudent(ctx, id, client.StudentPatch{Surname: client.Ptr("Rossi")})
+func Ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Serves the real handler on a fresh database built from schema.sql, with
+// one login, tester:secret.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CONFIG = defaultConfig()
+	DB, err = sql.Open("sqlite3_instrumented", filepath.Join(dir, "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	BLOBS = newLocalBlobStore(filepath.Join(dir, "blobs"))
+	if _, err := DB.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(DB); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DB.Exec("INSERT INTO credentials (user, password) VALUES(?, ?)", "tester", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(newHandler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, options ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(server.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// A class of students, a teacher of it and a remark to observe them with.
+func createClassroom(t *testing.T, c *client.Client, names ...string) (client.Teacher, []client.Student, client.Remark) {
+	t.Helper()
+	ctx := context.Background()
+	class, err := c.CreateClass(ctx, client.ClassInput{Name: "1A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teacher, err := c.CreateTeacher(ctx, client.TeacherInput{Name: "Maria", Surname: "Bianchi", ClassIds: []int64{class.Id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var students []client.Student
+	for _, name := range names {
+		student, err := c.CreateStudent(ctx, client.StudentInput{Name: name, Surname: "Rossi", ClassId: class.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		students = append(students, student)
+	}
+	remark, err := c.CreateRemark(ctx, client.RemarkInput{SkillId: 1, Level: 1, Description: "Hands work in on time", Code: "on-time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return teacher, students, remark
+}
+
+func TestClientBasicAuth(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	for _, options := range [][]client.Option{
+		nil,
+		{client.WithCredentials("tester", "wrong")},
+		{client.WithCredentials("nobody", "secret")},
+	} {
+		_, err := newTestClient(t, server, options...).ListStudents(ctx)
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %v, want a 401 APIError", err)
+		}
+	}
+
+	students, err := newTestClient(t, server, client.WithCredentials("tester", "secret")).ListStudents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 0 {
+		t.Errorf("got %d students on a fresh database", len(students))
+	}
+}
+
+func TestClientIterators(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+
+	class, err := c.CreateClass(ctx, client.ClassInput{Name: "1A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created []int64
+	for _, name := range []string{"Ada", "Bruno", "Carla", "Dario", "Elena", "Fabio", "Gina"} {
+		student, err := c.CreateStudent(ctx, client.StudentInput{Name: name, Surname: "Rossi", ClassId: class.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, student.Id)
+	}
+
+	// Page sizes that split the students evenly, unevenly and not at all
+	for _, pageSize := range []int{1, 3, 7, 10} {
+		it := c.IterStudents(pageSize)
+		var seen []int64
+		for it.Next(ctx) {
+			seen = append(seen, it.Value().Id)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("page size %d: %v", pageSize, err)
+		}
+		if len(seen) != len(created) {
+			t.Fatalf("page size %d: got students %v, want %v", pageSize, seen, created)
+		}
+		for i := range seen {
+			if seen[i] != created[i] {
+				t.Fatalf("page size %d: got students %v, want %v", pageSize, seen, created)
+			}
+		}
+	}
+
+	students, err := c.ListStudentsByClass(ctx, class.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != len(created) {
+		t.Errorf("got %d students in class %d, want %d", len(students), class.Id, len(created))
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+
+	_, err := c.GetStudent(ctx, 999)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "not found" {
+		t.Errorf("got %d %q, want 404 \"not found\"", apiErr.StatusCode, apiErr.Message)
+	}
+	if apiErr.Temporary() {
+		t.Errorf("a 404 isn't temporary")
+	}
+
+	_, err = c.CreateStudent(ctx, client.StudentInput{Name: "Ada", Surname: "Rossi", ClassId: 999})
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Message == "" {
+		t.Errorf("got %d %q, want 422 with the reason", apiErr.StatusCode, apiErr.Message)
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const DEFAULT_CONFIG_FILE = "config.json"
+
+// A time.Duration written as "90s", "24h" and so on in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(text)
+	return err
+}
+
+type Config struct {
+	DatabasePath    string   `json:"db_path"`
+	ListenAddress   string   `json:"listen"`
+	AllowedOrigins  []string `json:"allowed_origins"`
+	CorsMaxAge      Duration `json:"cors_max_age"`
+	TLSCert         string   `json:"tls_cert"`
+	TLSKey          string   `json:"tls_key"`
+	TLSClientAuth   string   `json:"tls_client_auth"`
+	TLSClientCA     string   `json:"tls_client_ca"`
+	RedirectListen  string   `json:"http_redirect_listen"`
+	HSTSMaxAge      Duration `json:"hsts_max_age"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout"`
+	IdleTimeout     Duration `json:"idle_timeout"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	MaxHeaderBytes  int      `json:"max_header_bytes"`
+	MaxBodyBytes    int64    `json:"max_body_bytes"`
+	MinFreeDisk     int64    `json:"min_free_disk_bytes"`
+	LogLevel        string   `json:"log_level"`
+	LogFormat       string   `json:"log_format"`
+	SessionLifetime Duration `json:"session_lifetime"`
+	BackupDir       string   `json:"backup_dir"`
+	BackupInterval  Duration `json:"backup_interval"`
+	BackupKeep      int      `json:"backup_keep"`
+	V1Sunset        string   `json:"api_v1_sunset"`
+	Timezone        string   `json:"timezone"`
+	TermStart       string   `json:"term_start"`
+	TermEnd         string   `json:"term_end"`
+	BlobDir         string   `json:"blob_dir"`
+	EvidenceMax     int64    `json:"evidence_max_bytes"`
+	BlobGCInterval  Duration `json:"blob_gc_interval"`
+	RolloverUndo    Duration `json:"rollover_undo_window"`
+}
+
+// Every setting can come from the config file (json key), the environment
+// (SVALUTATION_ + upper-cased key) or a flag (key with dashes). Later
+// sources win: defaults, file, environment, flags.
+var configSettings = []struct {
+	key   string
+	usage string
+}{
+	{"db_path", "path of the SQLite database"},
+	{"listen", "address the server listens on"},
+	{"allowed_origins", "comma-separated origins allowed by CORS, https://*.example.com allows every subdomain"},
+	{"cors_max_age", "how long browsers may cache a CORS preflight"},
+	{"tls_cert", "TLS certificate file, enables HTTPS together with tls_key"},
+	{"tls_key", "TLS private key file"},
+	{"tls_client_auth", "client certificates: none, optional or require"},
+	{"tls_client_ca", "CA bundle that signs accepted client certificates"},
+	{"http_redirect_listen", "plain HTTP address redirecting to HTTPS, empty disables it"},
+	{"hsts_max_age", "Strict-Transport-Security max-age sent over HTTPS, 0 disables it"},
+	{"read_timeout", "time allowed to read a whole request"},
+	{"write_timeout", "time allowed to write a whole response"},
+	{"idle_timeout", "how long idle keep-alive connections stay open"},
+	{"shutdown_timeout", "how long shutdown waits for in-flight requests"},
+	{"max_header_bytes", "largest accepted request header"},
+	{"max_body_bytes", "largest accepted request body"},
+	{"min_free_disk_bytes", "free space the database directory needs for /readyz to pass"},
+	{"log_level", "debug, info, warn or error"},
+	{"log_format", "text or json"},
+	{"session_lifetime", "how long a successful login is remembered, 0 checks the password on every request"},
+	{"backup_dir", "directory for scheduled backups"},
+	{"backup_interval", "time between scheduled backups, 0 disables them"},
+	{"backup_keep", "number of scheduled backups to keep, 0 keeps all"},
+	{"api_v1_sunset", "date (YYYY-MM-DD) the deprecated v1 API goes away, sent in a Sunset header; empty announces none"},
+	{"timezone", "IANA time zone of the school, used to turn dates into days"},
+	{"term_start", "first day (YYYY-MM-DD) of the current term, observations can't be dated before it; ignored once terms are kept in the database"},
+	{"term_end", "last day (YYYY-MM-DD) of the current term, observations can't be dated after it; ignored once terms are kept in the database"},
+	{"blob_dir", "directory for evidence files attached to observations"},
+	{"evidence_max_bytes", "largest accepted evidence file, must be below max_body_bytes"},
+	{"blob_gc_interval", "time between sweeps removing evidence files nothing refers to, 0 disables them"},
+	{"rollover_undo_window", "how long after a school year rollover it can still be undone, 0 disables undo"},
+}
+
+var CONFIG = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		DatabasePath:    "./database.db",
+		ListenAddress:   ":8080",
+		AllowedOrigins:  []string{"http://85.235.150.118:3000"},
+		CorsMaxAge:      Duration{10 * time.Minute},
+		TLSClientAuth:   "none",
+		HSTSMaxAge:      Duration{365 * 24 * time.Hour},
+		ReadTimeout:     Duration{30 * time.Second},
+		WriteTimeout:    Duration{2 * time.Minute},
+		IdleTimeout:     Duration{2 * time.Minute},
+		ShutdownTimeout: Duration{30 * time.Second},
+		MaxHeaderBytes:  64 << 10,
+		MaxBodyBytes:    10 << 20,
+		MinFreeDisk:     100 << 20,
+		LogLevel:        "info",
+		LogFormat:       "text",
+		SessionLifetime: Duration{5 * time.Minute},
+		BackupDir:       "backups",
+		BackupKeep:      7,
+		Timezone:        "UTC",
+		BlobDir:         "attachments",
+		EvidenceMax:     8 << 20,
+		BlobGCInterval:  Duration{24 * time.Hour},
+		RolloverUndo:    Duration{7 * 24 * time.Hour},
+	}
+}
+
+func (c *Config) get(key string) string {
+	switch key {
+	case "db_path":
+		return c.DatabasePath
+	case "listen":
+		return c.ListenAddress
+	case "allowed_origins":
+		return strings.Join(c.AllowedOrigins, ",")
+	case "cors_max_age":
+		return c.CorsMaxAge.String()
+	case "tls_cert":
+		return c.TLSCert
+	case "tls_key":
+		return c.TLSKey
+	case "tls_client_auth":
+		return c.TLSClientAuth
+	case "tls_client_ca":
+		return c.TLSClientCA
+	case "http_redirect_listen":
+		return c.RedirectListen
+	case "hsts_max_age":
+		return c.HSTSMaxAge.String()
+	case "read_timeout":
+		return c.ReadTimeout.String()
+	case "write_timeout":
+		return c.WriteTimeout.String()
+	case "idle_timeout":
+		return c.IdleTimeout.String()
+	case "shutdown_timeout":
+		return c.ShutdownTimeout.String()
+	case "max_header_bytes":
+		return strconv.Itoa(c.MaxHeaderBytes)
+	case "max_body_bytes":
+		return strconv.FormatInt(c.MaxBodyBytes, 10)
+	case "min_free_disk_bytes":
+		return strconv.FormatInt(c.MinFreeDisk, 10)
+	case "log_level":
+		return c.LogLevel
+	case "log_format":
+		return c.LogFormat
+	case "session_lifetime":
+		return c.SessionLifetime.String()
+	case "backup_dir":
+		return c.BackupDir
+	case "backup_interval":
+		return c.BackupInterval.String()
+	case "backup_keep":
+		return strconv.Itoa(c.BackupKeep)
+	case "api_v1_sunset":
+		return c.V1Sunset
+	case "timezone":
+		return c.Timezone
+	case "term_start":
+		return c.TermStart
+	case "term_end":
+		return c.TermEnd
+	case "blob_dir":
+		return c.BlobDir
+	case "evidence_max_bytes":
+		return strconv.FormatInt(c.EvidenceMax, 10)
+	case "blob_gc_interval":
+		return c.BlobGCInterval.String()
+	case "rollover_undo_window":
+		return c.RolloverUndo.String()
+	}
+	return ""
+}
+
+func (c *Config) set(key string, value string) error {
+	var err error
+	switch key {
+	case "db_path":
+		c.DatabasePath = value
+	case "listen":
+		c.ListenAddress = value
+	case "allowed_origins":
+		c.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, origin)
+			}
+		}
+	case "cors_max_age":
+		c.CorsMaxAge.Duration, err = time.ParseDuration(value)
+	case "tls_cert":
+		c.TLSCert = value
+	case "tls_key":
+		c.TLSKey = value
+	case "tls_client_auth":
+		c.TLSClientAuth = value
+	case "tls_client_ca":
+		c.TLSClientCA = value
+	case "http_redirect_listen":
+		c.RedirectListen = value
+	case "hsts_max_age":
+		c.HSTSMaxAge.Duration, err = time.ParseDuration(value)
+	case "read_timeout":
+		c.ReadTimeout.Duration, err = time.ParseDuration(value)
+	case "write_timeout":
+		c.WriteTimeout.Duration, err = time.ParseDuration(value)
+	case "idle_timeout":
+		c.IdleTimeout.Duration, err = time.ParseDuration(value)
+	case "shutdown_timeout":
+		c.ShutdownTimeout.Duration, err = time.ParseDuration(value)
+	case "max_header_bytes":
+		c.MaxHeaderBytes, err = strconv.Atoi(value)
+	case "max_body_bytes":
+		c.MaxBodyBytes, err = strconv.ParseInt(value, 10, 64)
+	case "min_free_disk_bytes":
+		c.MinFreeDisk, err = strconv.ParseInt(value, 10, 64)
+	case "log_level":
+		c.LogLevel = value
+	case "log_format":
+		c.LogFormat = value
+	case "session_lifetime":
+		c.SessionLifetime.Duration, err = time.ParseDuration(value)
+	case "backup_dir":
+		c.BackupDir = value
+	case "backup_interval":
+		c.BackupInterval.Duration, err = time.ParseDuration(value)
+	case "backup_keep":
+		c.BackupKeep, err = strconv.Atoi(value)
+	case "api_v1_sunset":
+		c.V1Sunset = value
+	case "timezone":
+		c.Timezone = value
+	case "term_start":
+		c.TermStart = value
+	case "term_end":
+		c.TermEnd = value
+	case "blob_dir":
+		c.BlobDir = value
+	case "evidence_max_bytes":
+		c.EvidenceMax, err = strconv.ParseInt(value, 10, 64)
+	case "blob_gc_interval":
+		c.BlobGCInterval.Duration, err = time.ParseDuration(value)
+	case "rollover_undo_window":
+		c.RolloverUndo.Duration, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	if c.DatabasePath == "" {
+		errs = append(errs, errors.New("db_path is required"))
+	}
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	}
+	for _, origin := range c.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("allowed_origins: %q is not a scheme://host[:port] origin", origin))
+		}
+	}
+	if c.CorsMaxAge.Duration < 0 {
+		errs = append(errs, errors.New("cors_max_age can't be negative"))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls_cert and tls_key must be set together"))
+	}
+	switch c.TLSClientAuth {
+	case "none":
+	case "optional", "require":
+		if c.TLSCert == "" || c.TLSClientCA == "" {
+			errs = append(errs, errors.New("tls_client_auth needs tls_cert, tls_key and tls_client_ca"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls_client_auth: %q is not none, optional or require", c.TLSClientAuth))
+	}
+	if c.RedirectListen != "" {
+		if _, _, err := net.SplitHostPort(c.RedirectListen); err != nil {
+			errs = append(errs, fmt.Errorf("http_redirect_listen: %w", err))
+		}
+		if c.TLSCert == "" {
+			errs = append(errs, errors.New("http_redirect_listen needs tls_cert and tls_key"))
+		}
+	}
+	if c.HSTSMaxAge.Duration < 0 {
+		errs = append(errs, errors.New("hsts_max_age can't be negative"))
+	}
+	if c.ReadTimeout.Duration <= 0 || c.WriteTimeout.Duration <= 0 || c.IdleTimeout.Duration <= 0 || c.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("read_timeout, write_timeout, idle_timeout and shutdown_timeout must be positive"))
+	}
+	if c.MaxHeaderBytes <= 0 || c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max_header_bytes and max_body_bytes must be positive"))
+	}
+	if c.MinFreeDisk < 0 {
+		errs = append(errs, errors.New("min_free_disk_bytes can't be negative"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %w", err))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format: %q is not text or json", c.LogFormat))
+	}
+	if c.SessionLifetime.Duration < 0 {
+		errs = append(errs, errors.New("session_lifetime can't be negative"))
+	}
+	if c.BackupInterval.Duration < 0 {
+		errs = append(errs, errors.New("backup_interval can't be negative"))
+	}
+	if c.BackupKeep < 0 {
+		errs = append(errs, errors.New("backup_keep can't be negative"))
+	}
+	if c.V1Sunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.V1Sunset); err != nil {
+			errs = append(errs, fmt.Errorf("api_v1_sunset: %q is not a YYYY-MM-DD date", c.V1Sunset))
+		}
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("timezone: %w", err))
+	}
+	if (c.TermStart == "") != (c.TermEnd == "") {
+		errs = append(errs, errors.New("term_start and term_end must be set together"))
+	}
+	if c.TermStart != "" {
+		start, startErr := time.Parse(time.DateOnly, c.TermStart)
+		end, endErr := time.Parse(time.DateOnly, c.TermEnd)
+		switch {
+		case startErr != nil:
+			errs = append(errs, fmt.Errorf("term_start: %q is not a YYYY-MM-DD date", c.TermStart))
+		case endErr != nil:
+			errs = append(errs, fmt.Errorf("term_end: %q is not a YYYY-MM-DD date", c.TermEnd))
+		case end.Before(start):
+			errs = append(errs, errors.New("term_end can't be before term_start"))
+		}
+	}
+	if c.BlobDir == "" {
+		errs = append(errs, errors.New("blob_dir is required"))
+	}
+	if c.EvidenceMax <= 0 || c.EvidenceMax >= c.MaxBodyBytes {
+		errs = append(errs, errors.New("evidence_max_bytes must be positive and below max_body_bytes"))
+	}
+	if c.BlobGCInterval.Duration < 0 {
+		errs = append(errs, errors.New("blob_gc_interval can't be negative"))
+	}
+	if c.RolloverUndo.Duration < 0 {
+		errs = append(errs, errors.New("rollover_undo_window can't be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) slogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+func (c *Config) v1Sunset() (time.Time, bool) {
+	sunset, err := time.Parse(time.DateOnly, c.V1Sunset)
+	return sunset, err == nil
+}
+
+func (c *Config) location() *time.Location {
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// The current term as the instants it starts and ends, the end being
+// midnight after term_end in the school's time zone.
+func (c *Config) term() (time.Time, time.Time, bool) {
+	start, startErr := time.ParseInLocation(time.DateOnly, c.TermStart, c.location())
+	end, endErr := time.ParseInLocation(time.DateOnly, c.TermEnd, c.location())
+	return start, end.AddDate(0, 0, 1), startErr == nil && endErr == nil
+}
+
+// Builds the effective configuration for a command from its arguments and
+// returns the arguments left after the flags.
+func loadConfig(command string, args []string) (Config, []string, error) {
+	config := defaultConfig()
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	configFile := flags.String("config", "", "config file, defaults to $SVALUTATION_CONFIG or "+DEFAULT_CONFIG_FILE+" if present")
+	for _, setting := range configSettings {
+		flags.String(strings.ReplaceAll(setting.key, "_", "-"), config.get(setting.key), setting.usage)
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return config, nil, err
+	}
+
+	path, explicit := *configFile, true
+	if path == "" {
+		path = os.Getenv("SVALUTATION_CONFIG")
+	}
+	if path == "" {
+		path, explicit = DEFAULT_CONFIG_FILE, false
+	}
+	data, readErr := os.ReadFile(path)
+	if readErr == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			return config, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if explicit || !errors.Is(readErr, os.ErrNotExist) {
+		return config, nil, readErr
+	}
+
+	for _, setting := range configSettings {
+		value, ok := os.LookupEnv("SVALUTATION_" + strings.ToUpper(setting.key))
+		if !ok {
+			continue
+		}
+		if err := config.set(setting.key, value); err != nil {
+			return config, nil, fmt.Errorf("environment: %w", err)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && err == nil {
+			err = config.set(strings.ReplaceAll(f.Name, "-", "_"), f.Value.String())
+		}
+	})
+	if err != nil {
+		return config, nil, err
+	}
+
+	return config, flags.Args(), config.validate()
+}
+
+// config print: show the effective configuration as JSON.
+func printConfig() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(CONFIG)
+}
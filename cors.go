@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var CORS_METHODS = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+const CORS_HEADERS = "Origin, Content-Type, Accept, Authorization, " + REQUEST_ID_HEADER
+
+// Response headers scripts on allowed origins may read
+const CORS_EXPOSE_HEADERS = REQUEST_ID_HEADER + ", Location, Deprecation, Sunset, Link"
+
+// Reports whether origin matches an allowlist entry, either exactly or, for
+// entries like https://*.example.com, as a subdomain of the wildcard host.
+func originAllowed(origin string, allowed []string) bool {
+	o, err := url.Parse(origin)
+	if err != nil || o.Scheme == "" || o.Host == "" {
+		return false
+	}
+	for _, entry := range allowed {
+		if strings.EqualFold(entry, origin) {
+			return true
+		}
+		e, err := url.Parse(entry)
+		if err != nil || !strings.HasPrefix(e.Host, "*.") || !strings.EqualFold(e.Scheme, o.Scheme) {
+			continue
+		}
+		if e.Port() != o.Port() {
+			continue
+		}
+		suffix := strings.ToLower(strings.TrimPrefix(e.Hostname(), "*"))
+		if strings.HasSuffix(strings.ToLower(o.Hostname()), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// The methods mux has a route for on r's path, so preflights only advertise
+// what the route really accepts.
+func routeMethods(mux *http.ServeMux, r *http.Request) []string {
+	var methods []string
+	for _, method := range CORS_METHODS {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// Adds CORS headers to every response of mux and answers OPTIONS requests,
+// including preflights, without reaching the routes.
+func cors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && originAllowed(origin, CONFIG.AllowedOrigins)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Expose-Headers", CORS_EXPOSE_HEADERS)
+		}
+
+		if r.Method != http.MethodOptions {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		methods := strings.Join(append(routeMethods(mux, r), http.MethodOptions), ", ")
+		w.Header().Set("Allow", methods)
+		if allowed && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", CORS_HEADERS)
+			if CONFIG.CorsMaxAge.Duration > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(CONFIG.CorsMaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
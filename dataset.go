@@ -0,0 +1,635 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Bump whenever the shape of Dataset changes; import refuses newer documents.
+const DATASET_VERSION = 10
+
+// A school's whole dataset, independent of the ids of the database it came
+// from. Ids inside the document only link its own records together.
+type Dataset struct {
+	Version      int
+	ExportedAt   time.Time
+	Years        []SchoolYear
+	Terms        []Term
+	Classes      []DatasetClass
+	Teachers     []DatasetTeacher
+	Assignments  []DatasetAssignment
+	Subjects     []Subject
+	Teaching     []DatasetTeaching
+	Framework    []FrameworkNode
+	Skills       []DatasetSkill
+	Remarks      []Remark
+	Students     []DatasetStudent
+	Enrollments  []DatasetEnrollment
+	Outcomes     []Outcome
+	Observations []DatasetObservation
+}
+
+// Before version 5 classes have no Year.
+type DatasetClass struct {
+	Id   int64
+	Name string
+	Year *int64
+}
+
+type DatasetTeacher struct {
+	Id      int64
+	Name    string
+	Surname string
+}
+
+// Before version 9 assignments have no Role and are lead.
+type DatasetAssignment struct {
+	Teacher int64
+	Class   int64
+	Role    string
+}
+
+// Before version 8 there are no subjects, so no teaching either.
+type DatasetTeaching struct {
+	Teacher int64
+	Class   int64
+	Subject int64
+}
+
+// Before version 8 skills have no Subject, before version 10 no Node.
+type DatasetSkill struct {
+	Id      int64
+	Name    string
+	Subject *int64
+	Node    *int64
+}
+
+// Before version 6 students have no Graduated, the year they finished in.
+type DatasetStudent struct {
+	Id        int64
+	Name      string
+	Surname   string
+	Class     int64
+	Graduated *int64
+}
+
+// Before version 7 there are no enrollments, students imported from older
+// documents are enrolled in their class.
+type DatasetEnrollment struct {
+	Student int64
+	Class   int64
+	Start   *string
+	End     *string
+}
+
+// Version 1 documents only have Achieved, which maps to an outcome the way
+// v1 writes do. Before version 3 RecordedAt is missing and taken from Date.
+// Evidence files stay out of datasets, only the notes travel.
+type DatasetObservation struct {
+	Id         int64
+	Teacher    int64
+	Student    int64
+	Remark     int64
+	Achieved   bool
+	Outcome    string
+	Score      *float64
+	Date       time.Time
+	RecordedAt time.Time
+	Notes      string
+}
+
+// How many records of each kind an import created and how many it matched
+// to existing rows by natural key.
+type DatasetImportReport struct {
+	Created map[string]int
+	Matched map[string]int
+}
+
+func exportDataset(db *sql.DB) (Dataset, error) {
+	dataset := Dataset{Version: DATASET_VERSION, ExportedAt: time.Now().UTC()}
+
+	queries := []struct {
+		query string
+		scan  func(rows *sql.Rows) error
+	}{
+		{"SELECT id, name, start_date, end_date FROM school_years", func(rows *sql.Rows) error {
+			var year SchoolYear
+			err := scanSchoolYear(rows, &year)
+			dataset.Years = append(dataset.Years, year)
+			return err
+		}},
+		{"SELECT id, year, name, start_date, end_date FROM terms", func(rows *sql.Rows) error {
+			var term Term
+			err := scanTerm(rows, &term)
+			dataset.Terms = append(dataset.Terms, term)
+			return err
+		}},
+		{"SELECT id, name, year FROM classes", func(rows *sql.Rows) error {
+			var class DatasetClass
+			err := rows.Scan(&class.Id, &class.Name, &class.Year)
+			dataset.Classes = append(dataset.Classes, class)
+			return err
+		}},
+		{"SELECT id, name, surname FROM teachers", func(rows *sql.Rows) error {
+			var teacher DatasetTeacher
+			err := rows.Scan(&teacher.Id, &teacher.Name, &teacher.Surname)
+			dataset.Teachers = append(dataset.Teachers, teacher)
+			return err
+		}},
+		{"SELECT teacher_id, class_id, role FROM classes_teachers ORDER BY id", func(rows *sql.Rows) error {
+			var assignment DatasetAssignment
+			err := rows.Scan(&assignment.Teacher, &assignment.Class, &assignment.Role)
+			dataset.Assignments = append(dataset.Assignments, assignment)
+			return err
+		}},
+		{"SELECT id, name FROM subjects", func(rows *sql.Rows) error {
+			var subject Subject
+			err := scanSubject(rows, &subject)
+			dataset.Subjects = append(dataset.Subjects, subject)
+			return err
+		}},
+		{"SELECT teacher, class, subject FROM teacher_subjects ORDER BY id", func(rows *sql.Rows) error {
+			var teaching DatasetTeaching
+			err := rows.Scan(&teaching.Teacher, &teaching.Class, &teaching.Subject)
+			dataset.Teaching = append(dataset.Teaching, teaching)
+			return err
+		}},
+		{"SELECT id, parent, name, code FROM framework_nodes ORDER BY id", func(rows *sql.Rows) error {
+			var node FrameworkNode
+			err := scanFrameworkNode(rows, &node)
+			dataset.Framework = append(dataset.Framework, node)
+			return err
+		}},
+		{"SELECT id, name, subject, node FROM skills", func(rows *sql.Rows) error {
+			var skill DatasetSkill
+			err := rows.Scan(&skill.Id, &skill.Name, &skill.Subject, &skill.Node)
+			dataset.Skills = append(dataset.Skills, skill)
+			return err
+		}},
+		{"SELECT id, skill, level, description, code, archived FROM remarks", func(rows *sql.Rows) error {
+			var remark Remark
+			err := rows.Scan(&remark.Id, &remark.Skill, &remark.Level, &remark.Description, &remark.Code, &remark.Archived)
+			dataset.Remarks = append(dataset.Remarks, remark)
+			return err
+		}},
+		{"SELECT id, name, surname, class, graduated FROM students", func(rows *sql.Rows) error {
+			var student DatasetStudent
+			err := rows.Scan(&student.Id, &student.Name, &student.Surname, &student.Class, &student.Graduated)
+			dataset.Students = append(dataset.Students, student)
+			return err
+		}},
+		{"SELECT student, class, start_date, end_date FROM enrollments ORDER BY id", func(rows *sql.Rows) error {
+			var enrollment DatasetEnrollment
+			err := rows.Scan(&enrollment.Student, &enrollment.Class, &enrollment.Start, &enrollment.End)
+			dataset.Enrollments = append(dataset.Enrollments, enrollment)
+			return err
+		}},
+		{"SELECT code, label, rank, achieved FROM outcomes ORDER BY rank", func(rows *sql.Rows) error {
+			var outcome Outcome
+			err := scanOutcome(rows, &outcome)
+			dataset.Outcomes = append(dataset.Outcomes, outcome)
+			return err
+		}},
+		{"SELECT o.id, o.teacher, o.student, o.remark, COALESCE(s.achieved, 0), o.outcome, o.score, o.date, o.recorded_at, o.notes FROM observations o LEFT JOIN outcomes s ON s.code = o.outcome", func(rows *sql.Rows) error {
+			var observation DatasetObservation
+			err := rows.Scan(&observation.Id, &observation.Teacher, &observation.Student, &observation.Remark, &observation.Achieved, &observation.Outcome, &observation.Score, &observation.Date, &observation.RecordedAt, &observation.Notes)
+			dataset.Observations = append(dataset.Observations, observation)
+			return err
+		}},
+	}
+
+	for _, q := range queries {
+		rows, err := db.Query(q.query)
+		if err != nil {
+			return dataset, err
+		}
+		for rows.Next() {
+			if err := q.scan(rows); err != nil {
+				rows.Close()
+				return dataset, err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return dataset, err
+		}
+	}
+	return dataset, nil
+}
+
+// Merges the dataset into db in a single transaction. Every record is first
+// looked up by its natural key and reused if found, so importing the same
+// document twice is a no-op.
+func importDataset(db *sql.DB, dataset Dataset) (DatasetImportReport, error) {
+	report := DatasetImportReport{Created: map[string]int{}, Matched: map[string]int{}}
+	if dataset.Version < 1 || dataset.Version > DATASET_VERSION {
+		return report, fmt.Errorf("unsupported dataset version %d, expected at most %d", dataset.Version, DATASET_VERSION)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	// Looks up a row by natural key and inserts it when missing, returning
+	// the id it has in this database. Rows without a natural key, an empty
+	// lookup, are always inserted.
+	resolve := func(kind string, lookup string, lookupArgs []any, insert string, insertArgs ...any) (int64, error) {
+		if lookup != "" {
+			var id int64
+			err := tx.QueryRow(lookup, lookupArgs...).Scan(&id)
+			if err == nil {
+				report.Matched[kind]++
+				return id, nil
+			}
+			if err != sql.ErrNoRows {
+				return 0, err
+			}
+		}
+		result, err := tx.Exec(insert, insertArgs...)
+		if err != nil {
+			return 0, err
+		}
+		report.Created[kind]++
+		return result.LastInsertId()
+	}
+	remap := func(kind string, ids map[int64]int64, id int64) (int64, error) {
+		newId, ok := ids[id]
+		if !ok {
+			return 0, fmt.Errorf("dataset references unknown %s %d", kind, id)
+		}
+		return newId, nil
+	}
+
+	years := map[int64]int64{}
+	for _, year := range dataset.Years {
+		years[year.Id], err = resolve("school_years",
+			"SELECT id FROM school_years WHERE name = ?", []any{year.Name},
+			"INSERT INTO school_years (name, start_date, end_date) VALUES(?, ?, ?)", year.Name, year.Start, year.End)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, term := range dataset.Terms {
+		year, err := remap("school year", years, term.Year)
+		if err != nil {
+			return report, err
+		}
+		_, err = resolve("terms",
+			"SELECT id FROM terms WHERE year = ? AND name = ?", []any{year, term.Name},
+			"INSERT INTO terms (year, name, start_date, end_date) VALUES(?, ?, ?, ?)", year, term.Name, term.Start, term.End)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// The same class name comes back every year, so classes match by name
+	// within their year
+	classes := map[int64]int64{}
+	for _, class := range dataset.Classes {
+		var year *int64
+		if class.Year != nil {
+			id, err := remap("school year", years, *class.Year)
+			if err != nil {
+				return report, err
+			}
+			year = &id
+		}
+		classes[class.Id], err = resolve("classes",
+			"SELECT id FROM classes WHERE name = ? AND year IS ?", []any{class.Name, year},
+			"INSERT INTO classes (name, year) VALUES(?, ?)", class.Name, year)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	teachers := map[int64]int64{}
+	for _, teacher := range dataset.Teachers {
+		teachers[teacher.Id], err = resolve("teachers",
+			"SELECT id FROM teachers WHERE name = ? AND surname = ?", []any{teacher.Name, teacher.Surname},
+			"INSERT INTO teachers (name, surname) VALUES(?, ?)", teacher.Name, teacher.Surname)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, assignment := range dataset.Assignments {
+		teacher, err := remap("teacher", teachers, assignment.Teacher)
+		if err != nil {
+			return report, err
+		}
+		class, err := remap("class", classes, assignment.Class)
+		if err != nil {
+			return report, err
+		}
+		role := assignment.Role
+		if role == "" {
+			role = DEFAULT_TEACHER_ROLE
+		}
+		if err := validateTeacherRole(role); err != nil {
+			return report, err
+		}
+		_, err = resolve("assignments",
+			"SELECT id FROM classes_teachers WHERE teacher_id = ? AND class_id = ?", []any{teacher, class},
+			"INSERT INTO classes_teachers (teacher_id, class_id, role) VALUES(?, ?, ?)", teacher, class, role)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	subjects := map[int64]int64{}
+	for _, subject := range dataset.Subjects {
+		subjects[subject.Id], err = resolve("subjects",
+			"SELECT id FROM subjects WHERE name = ?", []any{subject.Name},
+			"INSERT INTO subjects (name) VALUES(?)", subject.Name)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, teaching := range dataset.Teaching {
+		teacher, err := remap("teacher", teachers, teaching.Teacher)
+		if err != nil {
+			return report, err
+		}
+		class, err := remap("class", classes, teaching.Class)
+		if err != nil {
+			return report, err
+		}
+		subject, err := remap("subject", subjects, teaching.Subject)
+		if err != nil {
+			return report, err
+		}
+		_, err = resolve("teaching",
+			"SELECT id FROM teacher_subjects WHERE teacher = ? AND class = ? AND subject = ?", []any{teacher, class, subject},
+			"INSERT INTO teacher_subjects (teacher, class, subject) VALUES(?, ?, ?)", teacher, class, subject)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Nodes match by name among their siblings. A node can come before its
+	// parent, so the framework is resolved in passes until nothing is left.
+	nodes := map[int64]int64{}
+	pending := dataset.Framework
+	for len(pending) > 0 {
+		var left []FrameworkNode
+		for _, node := range pending {
+			var parent *int64
+			if node.Parent != nil {
+				id, ok := nodes[*node.Parent]
+				if !ok {
+					left = append(left, node)
+					continue
+				}
+				parent = &id
+			}
+			nodes[node.Id], err = resolve("framework_nodes",
+				"SELECT id FROM framework_nodes WHERE parent IS ? AND name = ?", []any{parent, node.Name},
+				"INSERT INTO framework_nodes (parent, name, code) VALUES(?, ?, ?)", parent, node.Name, node.Code)
+			if err != nil {
+				return report, err
+			}
+		}
+		if len(left) == len(pending) {
+			return report, fmt.Errorf("dataset references unknown framework node %d", *left[0].Parent)
+		}
+		pending = left
+	}
+
+	// Unnamed skills and remarks coded with their id, as migrated from
+	// before names and codes, have no natural key: their ids mean nothing
+	// in another database. They can only be imported into a database
+	// without skills and remarks of its own, where they are created.
+	var hasSkills, hasRemarks bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM skills), EXISTS (SELECT 1 FROM remarks)").Scan(&hasSkills, &hasRemarks)
+	if err != nil {
+		return report, err
+	}
+
+	// Named skills match by name
+	skills := map[int64]int64{}
+	for _, skill := range dataset.Skills {
+		var subject *int64
+		if skill.Subject != nil {
+			id, err := remap("subject", subjects, *skill.Subject)
+			if err != nil {
+				return report, err
+			}
+			subject = &id
+		}
+		var node *int64
+		if skill.Node != nil {
+			id, err := remap("framework node", nodes, *skill.Node)
+			if err != nil {
+				return report, err
+			}
+			node = &id
+		}
+		lookup, args := "SELECT id FROM skills WHERE name = ?", []any{skill.Name}
+		if skill.Name == "" {
+			if hasSkills {
+				return report, fmt.Errorf("skill %d has no name to match it with the skills of this database, name it before importing", skill.Id)
+			}
+			lookup, args = "", nil
+		}
+		skills[skill.Id], err = resolve("skills", lookup, args,
+			"INSERT INTO skills (name, subject, node) VALUES(?, ?, ?)", skill.Name, subject, node)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	remarks := map[int64]int64{}
+	for _, remark := range dataset.Remarks {
+		skill, err := remap("skill", skills, remark.Skill)
+		if err != nil {
+			return report, err
+		}
+		lookup, args := "SELECT id FROM remarks WHERE skill = ? AND level = ? AND code = ?", []any{skill, remark.Level, remark.Code}
+		if remark.Code == "" || remark.Code == strconv.FormatInt(remark.Id, 10) {
+			if hasRemarks {
+				return report, fmt.Errorf("remark %d has no code but its id to match it with the remarks of this database, give it a code before importing", remark.Id)
+			}
+			lookup, args = "", nil
+		}
+		remarks[remark.Id], err = resolve("remarks", lookup, args,
+			"INSERT INTO remarks (skill, level, description, code, archived) VALUES(?, ?, ?, ?, ?)", skill, remark.Level, remark.Description, remark.Code, remark.Archived)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	students := map[int64]int64{}
+	for _, student := range dataset.Students {
+		class, err := remap("class", classes, student.Class)
+		if err != nil {
+			return report, err
+		}
+		var graduated *int64
+		if student.Graduated != nil {
+			id, err := remap("school year", years, *student.Graduated)
+			if err != nil {
+				return report, err
+			}
+			graduated = &id
+		}
+		students[student.Id], err = resolve("students",
+			"SELECT id FROM students WHERE name = ? AND surname = ? AND class = ?", []any{student.Name, student.Surname, class},
+			"INSERT INTO students (name, surname, class, graduated) VALUES(?, ?, ?, ?)", student.Name, student.Surname, class, graduated)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, enrollment := range dataset.Enrollments {
+		student, err := remap("student", students, enrollment.Student)
+		if err != nil {
+			return report, err
+		}
+		class, err := remap("class", classes, enrollment.Class)
+		if err != nil {
+			return report, err
+		}
+		_, err = resolve("enrollments",
+			"SELECT id FROM enrollments WHERE student = ? AND class = ? AND start_date IS ?", []any{student, class, enrollment.Start},
+			"INSERT INTO enrollments (student, class, start_date, end_date) VALUES(?, ?, ?, ?)", student, class, enrollment.Start, enrollment.End)
+		if err != nil {
+			return report, err
+		}
+	}
+	result, err := tx.Exec("INSERT INTO enrollments (student, class) SELECT id, class FROM students WHERE id NOT IN (SELECT student FROM enrollments)")
+	if err != nil {
+		return report, err
+	}
+	if enrolled, _ := result.RowsAffected(); enrolled > 0 {
+		report.Created["enrollments"] += int(enrolled)
+	}
+
+	// Outcomes match by code, the scale of this database wins.
+	for _, outcome := range dataset.Outcomes {
+		var code string
+		err := tx.QueryRow("SELECT code FROM outcomes WHERE code = ?", outcome.Code).Scan(&code)
+		if err == nil {
+			report.Matched["outcomes"]++
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return report, err
+		}
+		_, err = tx.Exec("INSERT INTO outcomes (code, label, rank, achieved) VALUES(?, ?, ?, ?)", outcome.Code, outcome.Label, outcome.Rank, outcome.Achieved)
+		if err != nil {
+			return report, fmt.Errorf("can't add outcome %q: %w", outcome.Code, err)
+		}
+		report.Created["outcomes"]++
+	}
+
+	for _, observation := range dataset.Observations {
+		outcome := observation.Outcome
+		if outcome == "" {
+			outcome, err = outcomeForAchieved(tx, observation.Achieved)
+			if err != nil {
+				return report, err
+			}
+		}
+		if err := validateOutcome(tx, outcome); err != nil {
+			return report, err
+		}
+		teacher, err := remap("teacher", teachers, observation.Teacher)
+		if err != nil {
+			return report, err
+		}
+		student, err := remap("student", students, observation.Student)
+		if err != nil {
+			return report, err
+		}
+		remark, err := remap("remark", remarks, observation.Remark)
+		if err != nil {
+			return report, err
+		}
+		date, recordedAt := sqliteTime(observation.Date), sqliteTime(observation.RecordedAt)
+		if observation.RecordedAt.IsZero() {
+			recordedAt = date
+		}
+		created := report.Created["observations"]
+		id, err := resolve("observations",
+			"SELECT id FROM observations WHERE teacher = ? AND student = ? AND remark = ? AND datetime(date) = datetime(?)", []any{teacher, student, remark, date},
+			"INSERT INTO observations (teacher, student, remark, outcome, score, date, recorded_at, notes) VALUES(?, ?, ?, ?, ?, ?, ?, ?)", teacher, student, remark, outcome, observation.Score, date, recordedAt, observation.Notes)
+		if err != nil {
+			return report, err
+		}
+		// Matched observations are left as they are, new ones start their
+		// history with the import
+		if report.Created["observations"] > created {
+			err = recordRevision(tx, "", id, "import", nil)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, tx.Commit()
+}
+
+// export [file]: write the dataset as JSON, to stdout by default.
+func exportCommand(args []string) error {
+	var out io.Writer = os.Stdout
+	if len(args) > 0 && args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	err := migrate(DB)
+	if err != nil {
+		return err
+	}
+	dataset, err := exportDataset(DB)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dataset)
+}
+
+// import <file>: merge a dataset document into the database, "-" reads stdin.
+func importCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: import <file>")
+	}
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var dataset Dataset
+	err := json.NewDecoder(in).Decode(&dataset)
+	if err != nil {
+		return err
+	}
+	err = migrate(DB)
+	if err != nil {
+		return err
+	}
+	report, err := importDataset(DB, dataset)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+)
+
+// Observations may be dated a little ahead of the server clock, client
+// clocks are never quite right.
+const OBSERVATION_CLOCK_SKEW = 5 * time.Minute
+
+// Dates are stored in UTC with the layout of CURRENT_TIMESTAMP, so dates
+// sent by clients sort with the ones SQLite fills in.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// Parses a date sent by a client, which must carry its offset.
+func parseObservationDate(value string) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return date, invalid("date %q isn't an RFC 3339 timestamp like 2024-02-07T09:25:01+01:00", value)
+	}
+	return date, nil
+}
+
+// Observations can't be dated in the future or outside the current term,
+// the term containing today. Without terms in the database, term_start
+// and term_end set the current term.
+func validateObservationDate(q querier, date time.Time) error {
+	if date.After(time.Now().Add(OBSERVATION_CLOCK_SKEW)) {
+		return invalid("date %s is in the future", date.Format(time.RFC3339))
+	}
+	terms, err := loadTerms(q)
+	if err != nil {
+		return err
+	}
+	if len(terms) > 0 {
+		current := termOf(terms, time.Now())
+		if current != nil && termOf(terms, date) != current {
+			return invalid("date %s is outside the current term, %s to %s", date.In(CONFIG.location()).Format(time.RFC3339), current.Start, current.End)
+		}
+		return nil
+	}
+	start, end, ok := CONFIG.term()
+	if ok && (date.Before(start) || !date.Before(end)) {
+		return invalid("date %s is outside the current term, %s to %s", date.In(CONFIG.location()).Format(time.RFC3339), CONFIG.TermStart, CONFIG.TermEnd)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Students move between classes, mid-year or when a year is rolled over.
+// students.class is the class of the latest enrollment, enrollments keep
+// every class with the days spent in it so that older observations still
+// find the class they were made in. Days are YYYY-MM-DD in the school's
+// time zone.
+
+// Students enrolled in a class on a day, the class and the day twice as
+// arguments.
+const ENROLLED_ON = "id IN (SELECT student FROM enrollments WHERE class = ? AND (start_date IS NULL OR start_date <= ?) AND (end_date IS NULL OR end_date >= ?))"
+
+func dayOf(date time.Time) string {
+	return date.In(CONFIG.location()).Format(time.DateOnly)
+}
+
+func today() string {
+	return dayOf(time.Now())
+}
+
+func dayBefore(day string) string {
+	date, _ := time.Parse(time.DateOnly, day)
+	return date.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+func parseDay(field string, value string) (string, error) {
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return "", invalid("%s %q is not a YYYY-MM-DD date", field, value)
+	}
+	return value, nil
+}
+
+// Returns the enrollment covering day, enrollments being those of one
+// student.
+func enrollmentOn(enrollments []Enrollment, day string) *Enrollment {
+	for i, enrollment := range enrollments {
+		if (enrollment.Start == nil || *enrollment.Start <= day) && (enrollment.End == nil || day <= *enrollment.End) {
+			return &enrollments[i]
+		}
+	}
+	return nil
+}
+
+// The class a student was in on day, their current class when no
+// enrollment covers it.
+func classOn(q querier, studentId any, day string) (*int64, error) {
+	var class *int64
+	err := q.QueryRow(`SELECT COALESCE(
+		(SELECT class FROM enrollments WHERE student = ?1 AND (start_date IS NULL OR start_date <= ?2) AND (end_date IS NULL OR end_date >= ?2) LIMIT 1),
+		(SELECT class FROM students WHERE id = ?1))`, studentId, day).Scan(&class)
+	return class, err
+}
+
+// The first class of a new student, open at both ends.
+func enrollStudent(tx *sql.Tx, studentId int64, classId any) error {
+	_, err := tx.Exec("INSERT INTO enrollments (student, class) VALUES(?, ?)", studentId, classId)
+	return err
+}
+
+// Moves a student into classId from day on. The current enrollment ends
+// the day before, or is changed if it starts on day. A graduate moved
+// into a class is enrolled again.
+func moveStudent(tx *sql.Tx, studentId int64, classId int64, day string) error {
+	var id, class int64
+	var start *string
+	err := tx.QueryRow("SELECT id, class, start_date FROM enrollments WHERE student = ? AND end_date IS NULL", studentId).Scan(&id, &class, &start)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		var last *string
+		err = tx.QueryRow("SELECT MAX(end_date) FROM enrollments WHERE student = ?", studentId).Scan(&last)
+		if err != nil {
+			return err
+		}
+		if last != nil && *last >= day {
+			return invalid("student %d was enrolled until %s, the new class must start after", studentId, *last)
+		}
+		_, err = tx.Exec("INSERT INTO enrollments (student, class, start_date) VALUES(?, ?, ?)", studentId, classId, day)
+	case err != nil:
+		return err
+	case class == classId:
+	case start != nil && *start > day:
+		return invalid("student %d is in class %d since %s, the move can't take effect before", studentId, class, *start)
+	case start != nil && *start == day:
+		_, err = tx.Exec("UPDATE enrollments SET class = ? WHERE id = ?", classId, id)
+	default:
+		_, err = tx.Exec("UPDATE enrollments SET end_date = ? WHERE id = ?", dayBefore(day), id)
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO enrollments (student, class, start_date) VALUES(?, ?, ?)", studentId, classId, day)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE students SET class = ?, graduated = NULL WHERE id = ?", classId, studentId)
+	return err
+}
+
+// Ends the current enrollment of a student leaving school with year.
+func graduateStudent(tx *sql.Tx, studentId int64, year SchoolYear) error {
+	_, err := tx.Exec("UPDATE enrollments SET end_date = ? WHERE student = ? AND end_date IS NULL AND (start_date IS NULL OR start_date <= ?)", year.End, studentId, year.End)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE students SET graduated = ? WHERE id = ?", year.Id, studentId)
+	return err
+}
+
+// Takes back a moveStudent or graduateStudent: drops the enrollments from
+// day on and puts the student back in classId.
+func restoreStudent(tx *sql.Tx, studentId int64, classId int64, day string) error {
+	_, err := tx.Exec("DELETE FROM enrollments WHERE student = ? AND start_date >= ?", studentId, day)
+	if err != nil {
+		return err
+	}
+	var id, class int64
+	err = tx.QueryRow("SELECT id, class FROM enrollments WHERE student = ? ORDER BY start_date DESC LIMIT 1", studentId).Scan(&id, &class)
+	switch {
+	case err == nil && class == classId:
+		_, err = tx.Exec("UPDATE enrollments SET end_date = NULL WHERE id = ?", id)
+	case err == nil:
+		_, err = tx.Exec("INSERT INTO enrollments (student, class, start_date) VALUES(?, ?, ?)", studentId, classId, day)
+	case errors.Is(err, sql.ErrNoRows):
+		err = enrollStudent(tx, studentId, classId)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE students SET class = ?, graduated = NULL WHERE id = ?", classId, studentId)
+	return err
+}
+
+// ?as_of= narrowing a class list to the students enrolled on that day
+// instead of those in the class now.
+func asOfFilter(r *http.Request, classId any, conditions []string, args []any) ([]string, []any, error) {
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return append(conditions, "class = ?"), append(args, classId), nil
+	}
+	day, err := parseDay("as_of", value)
+	if err != nil {
+		return conditions, args, err
+	}
+	return append(conditions, ENROLLED_ON), append(args, classId, day, day), nil
+}
+
+func getStudentEnrollmentsV2(w http.ResponseWriter, r *http.Request) {
+	student, err := loadStudent(DB, r.PathValue("id"))
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	listV2(w, r, queryEnrollments, v2.FromEnrollment, "SELECT * FROM enrollments WHERE student = ?", student.Id)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestEnrollmentOn(t *testing.T) {
+	day := func(value string) *string { return &value }
+	enrollments := []Enrollment{
+		{Id: 1, End: day("2025-01-09")},
+		{Id: 2, Start: day("2025-01-10"), End: day("2025-02-28")},
+		{Id: 3, Start: day("2025-03-01")},
+	}
+	for _, test := range []struct {
+		day  string
+		want int64
+	}{
+		{"2020-06-01", 1},
+		{"2025-01-09", 1},
+		{"2025-01-10", 2},
+		{"2025-02-28", 2},
+		{"2025-03-01", 3},
+		{"2030-01-01", 3},
+	} {
+		enrollment := enrollmentOn(enrollments, test.day)
+		if enrollment == nil || enrollment.Id != test.want {
+			t.Errorf("%s: got %v, want enrollment %d", test.day, enrollment, test.want)
+		}
+	}
+	if enrollment := enrollmentOn(enrollments[1:2], "2025-03-01"); enrollment != nil {
+		t.Errorf("got enrollment %d for a day after it ended", enrollment.Id)
+	}
+}
+
+func TestEnrollmentHistory(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+
+	var classes []int64
+	for _, name := range []string{"1A", "1B", "1C"} {
+		class, err := c.CreateClass(ctx, client.ClassInput{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		classes = append(classes, class.Id)
+	}
+	student, err := c.CreateStudent(ctx, client.StudentInput{Name: "Ada", Surname: "Rossi", ClassId: classes[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, since := range []string{"2025-01-10", "2025-03-01"} {
+		_, err := c.UpdateStudent(ctx, student.Id, client.StudentPatch{ClassId: &classes[i+1], ClassSince: &since})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enrollments, err := c.ListEnrollments(ctx, student.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enrollments) != 3 {
+		t.Fatalf("got %d enrollments, want 3", len(enrollments))
+	}
+	for i, want := range [][2]string{{"", "2025-01-09"}, {"2025-01-10", "2025-02-28"}, {"2025-03-01", ""}} {
+		start, end := "", ""
+		if enrollments[i].StartDate != nil {
+			start = *enrollments[i].StartDate
+		}
+		if enrollments[i].EndDate != nil {
+			end = *enrollments[i].EndDate
+		}
+		if enrollments[i].Class.Id != classes[i] || start != want[0] || end != want[1] {
+			t.Errorf("enrollment %d: got class %d from %q to %q, want class %d from %q to %q", i, enrollments[i].Class.Id, start, end, classes[i], want[0], want[1])
+		}
+	}
+
+	// The class on each side of both moves
+	for _, test := range []struct {
+		day   string
+		class int
+	}{
+		{"2024-09-15", 0},
+		{"2025-01-09", 0},
+		{"2025-01-10", 1},
+		{"2025-02-28", 1},
+		{"2025-03-01", 2},
+	} {
+		for i, class := range classes {
+			students, err := c.ListStudentsByClassOn(ctx, class, test.day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enrolled := len(students) == 1 && students[0].Id == student.Id
+			if enrolled != (i == test.class) {
+				t.Errorf("%s: class %d lists %d students", test.day, i, len(students))
+			}
+		}
+		class, err := classOn(DB, student.Id, test.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if class == nil || *class != classes[test.class] {
+			t.Errorf("%s: classOn got %v, want %d", test.day, class, classes[test.class])
+		}
+	}
+
+	// A move can't take effect before the current class started
+	since := "2025-02-01"
+	_, err = c.UpdateStudent(ctx, student.Id, client.StudentPatch{ClassId: &classes[0], ClassSince: &since})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got %v, want a 422 APIError", err)
+	}
+	_, err = c.ListStudentsByClassOn(ctx, classes[0], "yesterday")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got %v, want a 422 APIError for as_of=yesterday", err)
+	}
+}
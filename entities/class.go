@@ -1,6 +1,9 @@
 package entities
 
+// Year is the school year of the class, nil for classes from before years
+// were kept. v1 doesn't show it.
 type Class struct {
 	Id   int64
 	Name string
+	Year *int64 `json:"-"`
 }
\ No newline at end of file
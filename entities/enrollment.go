@@ -0,0 +1,11 @@
+package entities
+
+// The days a student spent in a class, both ends included. Start is nil
+// for the first class a student was recorded in, End for the current one.
+type Enrollment struct {
+	Id      int64
+	Student int64
+	Class   Class
+	Start   *string
+	End     *string
+}
\ No newline at end of file
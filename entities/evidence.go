@@ -0,0 +1,19 @@
+package entities
+
+import (
+	"time"
+)
+
+// A file attached to an observation. Blob and Thumbnail are keys in the
+// blob store, Sha256 is nil for files stored before checksums were kept.
+type Evidence struct {
+	Id          int64
+	Observation int64
+	Filename    string
+	MediaType   string
+	Size        int64
+	Blob        string
+	UploadedAt  time.Time
+	Sha256      *string
+	Thumbnail   *string
+}
\ No newline at end of file
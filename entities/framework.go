@@ -0,0 +1,10 @@
+package entities
+
+// A key area, competency or any other level of the competency framework.
+// Roots have no Parent, skills hang from the leaves.
+type FrameworkNode struct {
+	Id     int64
+	Parent *int64
+	Name   string
+	Code   string
+}
\ No newline at end of file
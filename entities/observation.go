@@ -11,4 +11,13 @@ type Observation struct {
 	Remark   Remark
 	Achieved bool
 	Date     time.Time
+	// Only in v2, v1 responses keep their shape and derive Achieved
+	Outcome    string    `json:"-"`
+	Score      *float64  `json:"-"`
+	RecordedAt time.Time `json:"-"`
+	Notes      string    `json:"-"`
+	// The term containing Date, nil when no term does
+	Term *int64 `json:"-"`
+	// The class Student was in on Date, nil when no enrollment covers it
+	Class *Class `json:"-"`
 }
\ No newline at end of file
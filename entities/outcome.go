@@ -0,0 +1,10 @@
+package entities
+
+// A step of the outcome scale observations are recorded on. Higher ranks are
+// better; Achieved marks the steps that count as achieved.
+type Outcome struct {
+	Code     string
+	Label    string
+	Rank     int64
+	Achieved bool
+}
\ No newline at end of file
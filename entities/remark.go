@@ -5,4 +5,6 @@ type Remark struct {
 	Skill       int64
 	Level       int64
 	Description string
-}
\ No newline at end of file
+	Code        string
+	Archived    bool
+}
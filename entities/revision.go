@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"time"
+)
+
+// The state of an observation after a change. Previous is the revision
+// before it, nil for the first one, and gives the old values.
+type ObservationRevision struct {
+	Id           int64
+	Observation  int64
+	Action       string
+	User         *string
+	ChangedAt    time.Time
+	RevertedFrom *int64
+	Teacher      int64
+	Student      int64
+	Remark       int64
+	Date         time.Time
+	Outcome      string
+	Score        *float64
+	Notes        string
+	Previous     *ObservationRevision
+}
\ No newline at end of file
@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"time"
+)
+
+// A school year rolled over into the next one. Classes and Students record
+// where everyone went and Assignments the classes_teachers rows copied, so
+// that the rollover can be undone until UndoUntil.
+type Rollover struct {
+	Id           int64
+	From         int64
+	To           int64
+	User         *string
+	PerformedAt  time.Time
+	CopyTeachers bool
+	UndoneAt     *time.Time
+	UndoUntil    time.Time
+	Classes      []RolloverClass
+	Students     []RolloverStudent
+	Assignments  []int64
+}
+
+// Action is promote, retain or graduate. To is nil for graduate, Created
+// tells whether the rollover made the class, which undoing it removes.
+type RolloverClass struct {
+	Action  string
+	From    Class
+	To      *Class
+	Created bool
+}
+
+type RolloverStudent struct {
+	Student int64
+	Action  string
+	From    int64
+	To      *int64
+}
\ No newline at end of file
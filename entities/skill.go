@@ -0,0 +1,10 @@
+package entities
+
+type Skill struct {
+	Id   int64
+	Name string
+	// Only in v2, nil for skills of no particular subject
+	Subject *int64 `json:"-"`
+	// Only in v2, the framework leaf the skill belongs to
+	Node *int64 `json:"-"`
+}
\ No newline at end of file
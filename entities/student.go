@@ -5,4 +5,6 @@ type Student struct {
 	Name    string
 	Surname string
 	Class   Class
+	// The school year the student finished school in, nil while enrolled
+	Graduated *int64 `json:"-"`
 }
\ No newline at end of file
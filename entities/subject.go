@@ -0,0 +1,14 @@
+package entities
+
+type Subject struct {
+	Id   int64
+	Name string
+}
+
+// Teacher teaches Subject in Class.
+type SubjectAssignment struct {
+	Id      int64
+	Teacher int64
+	Class   int64
+	Subject int64
+}
\ No newline at end of file
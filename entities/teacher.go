@@ -5,4 +5,12 @@ type Teacher struct {
 	Name    string
 	Surname string
 	Classes []Class
+}
+
+// Teacher teaches in Class as Role, lead or support.
+type ClassTeacher struct {
+	Id      int64
+	Teacher Teacher
+	Class   int64
+	Role    string
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Class struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+	YearId *int64 `json:"year_id"`
+}
+
+type ClassInput struct {
+	Name   string `json:"name"`
+	YearId *int64 `json:"year_id,omitempty"`
+}
+
+// Fields left out are not changed.
+type ClassPatch struct {
+	Name   *string `json:"name,omitempty"`
+	YearId *int64  `json:"year_id,omitempty"`
+}
+
+func FromClass(class entities.Class) Class {
+	return Class{Id: class.Id, Name: class.Name, YearId: class.Year}
+}
\ No newline at end of file
@@ -0,0 +1,19 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+// Dates are YYYY-MM-DD, both included. start_date is null for the first
+// class a student was recorded in, end_date for the current one.
+type Enrollment struct {
+	Id        int64   `json:"id"`
+	StudentId int64   `json:"student_id"`
+	Class     Class   `json:"class"`
+	StartDate *string `json:"start_date"`
+	EndDate   *string `json:"end_date"`
+}
+
+func FromEnrollment(enrollment entities.Enrollment) Enrollment {
+	return Enrollment{Id: enrollment.Id, StudentId: enrollment.Student, Class: FromClass(enrollment.Class), StartDate: enrollment.Start, EndDate: enrollment.End}
+}
\ No newline at end of file
@@ -0,0 +1,34 @@
+// Package v2 holds the /api/v2 representations of the entities: snake_case
+// JSON fields, ids for references in request bodies and every response
+// wrapped in an envelope.
+package v2
+
+// A single resource.
+type Data[T any] struct {
+	Data T `json:"data"`
+}
+
+// One page of a list. Total counts every match, not just this page.
+type Page[T any] struct {
+	Data []T      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
+type PageMeta struct {
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+	Total  int64 `json:"total"`
+}
+
+// The body of every failed v2 request.
+type Error struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// Entries lists the rejected entries of a batch request.
+type ErrorDetail struct {
+	Status    int               `json:"status"`
+	Message   string            `json:"message"`
+	RequestId string            `json:"request_id"`
+	Entries   []BatchEntryError `json:"entries,omitempty"`
+}
\ No newline at end of file
@@ -0,0 +1,40 @@
+package v2
+
+import (
+	"api/entities"
+	"strconv"
+	"time"
+)
+
+// The file itself is at ContentUrl, a small JPEG of images at
+// ThumbnailUrl.
+type Evidence struct {
+	Id            int64     `json:"id"`
+	ObservationId int64     `json:"observation_id"`
+	Filename      string    `json:"filename"`
+	MediaType     string    `json:"media_type"`
+	Size          int64     `json:"size"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	Sha256        *string   `json:"sha256"`
+	ContentUrl    string    `json:"content_url"`
+	ThumbnailUrl  *string   `json:"thumbnail_url"`
+}
+
+func FromEvidence(evidence entities.Evidence) Evidence {
+	var thumbnailUrl *string
+	if evidence.Thumbnail != nil {
+		url := "/api/v2/evidence/thumbnail/" + strconv.FormatInt(evidence.Id, 10)
+		thumbnailUrl = &url
+	}
+	return Evidence{
+		Id:            evidence.Id,
+		ObservationId: evidence.Observation,
+		Filename:      evidence.Filename,
+		MediaType:     evidence.MediaType,
+		Size:          evidence.Size,
+		UploadedAt:    evidence.UploadedAt,
+		Sha256:        evidence.Sha256,
+		ContentUrl:    "/api/v2/evidence/content/" + strconv.FormatInt(evidence.Id, 10),
+		ThumbnailUrl:  thumbnailUrl,
+	}
+}
\ No newline at end of file
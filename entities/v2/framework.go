@@ -0,0 +1,30 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+// parent_id is null for the roots of the framework.
+type FrameworkNode struct {
+	Id       int64  `json:"id"`
+	ParentId *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+}
+
+type FrameworkNodeInput struct {
+	ParentId *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+}
+
+// Fields left out are not changed, a parent_id of 0 makes the node a root.
+type FrameworkNodePatch struct {
+	ParentId *int64  `json:"parent_id,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Code     *string `json:"code,omitempty"`
+}
+
+func FromFrameworkNode(node entities.FrameworkNode) FrameworkNode {
+	return FrameworkNode{Id: node.Id, ParentId: node.Parent, Name: node.Name, Code: node.Code}
+}
\ No newline at end of file
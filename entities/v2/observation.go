@@ -0,0 +1,95 @@
+package v2
+
+import (
+	"api/entities"
+	"time"
+)
+
+// Achieved is derived from the outcome. Date is when the student was
+// observed, RecordedAt when the observation was entered. TermId is the term
+// containing Date and Class the class the student was in then, unlike
+// Student.Class which is the current one.
+type Observation struct {
+	Id         int64     `json:"id"`
+	Teacher    Teacher   `json:"teacher"`
+	Student    Student   `json:"student"`
+	Remark     Remark    `json:"remark"`
+	Outcome    string    `json:"outcome"`
+	Score      *float64  `json:"score"`
+	Achieved   bool      `json:"achieved"`
+	Date       time.Time `json:"date"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Notes      string    `json:"notes"`
+	TermId     *int64    `json:"term_id"`
+	Class      *Class    `json:"class"`
+}
+
+// Outcome is the code of a step of the outcome scale. Date defaults to now.
+type ObservationInput struct {
+	TeacherId int64      `json:"teacher_id"`
+	StudentId int64      `json:"student_id"`
+	RemarkId  int64      `json:"remark_id"`
+	Outcome   string     `json:"outcome"`
+	Score     *float64   `json:"score,omitempty"`
+	Date      *time.Time `json:"date,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+}
+
+// Fields left out are not changed.
+type ObservationPatch struct {
+	TeacherId *int64     `json:"teacher_id,omitempty"`
+	StudentId *int64     `json:"student_id,omitempty"`
+	RemarkId  *int64     `json:"remark_id,omitempty"`
+	Outcome   *string    `json:"outcome,omitempty"`
+	Score     *float64   `json:"score,omitempty"`
+	Date      *time.Time `json:"date,omitempty"`
+	Notes     *string    `json:"notes,omitempty"`
+}
+
+func FromObservation(observation entities.Observation) Observation {
+	result := Observation{
+		Id:         observation.Id,
+		Teacher:    FromTeacher(observation.Teacher),
+		Student:    FromStudent(observation.Student),
+		Remark:     FromRemark(observation.Remark),
+		Outcome:    observation.Outcome,
+		Score:      observation.Score,
+		Achieved:   observation.Achieved,
+		Date:       observation.Date,
+		RecordedAt: observation.RecordedAt,
+		Notes:      observation.Notes,
+		TermId:     observation.Term,
+	}
+	if observation.Class != nil {
+		class := FromClass(*observation.Class)
+		result.Class = &class
+	}
+	return result
+}
+
+// One remark recorded for many students at once.
+type ObservationBatch struct {
+	TeacherId int64                   `json:"teacher_id"`
+	RemarkId  int64                   `json:"remark_id"`
+	Date      *time.Time              `json:"date,omitempty"`
+	Entries   []ObservationBatchEntry `json:"entries"`
+}
+
+type ObservationBatchEntry struct {
+	StudentId int64    `json:"student_id"`
+	Outcome   string   `json:"outcome"`
+	Score     *float64 `json:"score,omitempty"`
+	Notes     string   `json:"notes,omitempty"`
+}
+
+// Ids of the created observations in entry order, skipping failed entries.
+type ObservationBatchResult struct {
+	Ids    []int64           `json:"ids"`
+	Failed []BatchEntryError `json:"failed"`
+}
+
+type BatchEntryError struct {
+	Index     int    `json:"index"`
+	StudentId int64  `json:"student_id"`
+	Message   string `json:"message"`
+}
\ No newline at end of file
@@ -0,0 +1,20 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Outcome struct {
+	Code     string `json:"code"`
+	Label    string `json:"label"`
+	Rank     int64  `json:"rank"`
+	Achieved bool   `json:"achieved"`
+}
+
+func FromOutcome(outcome entities.Outcome) Outcome {
+	return Outcome{Code: outcome.Code, Label: outcome.Label, Rank: outcome.Rank, Achieved: outcome.Achieved}
+}
+
+func ToOutcome(outcome Outcome) entities.Outcome {
+	return entities.Outcome{Code: outcome.Code, Label: outcome.Label, Rank: outcome.Rank, Achieved: outcome.Achieved}
+}
\ No newline at end of file
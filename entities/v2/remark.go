@@ -0,0 +1,34 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Remark struct {
+	Id          int64  `json:"id"`
+	SkillId     int64  `json:"skill_id"`
+	Level       int64  `json:"level"`
+	Description string `json:"description"`
+	Code        string `json:"code"`
+	Archived    bool   `json:"archived"`
+}
+
+type RemarkInput struct {
+	SkillId     int64  `json:"skill_id"`
+	Level       int64  `json:"level"`
+	Description string `json:"description"`
+	Code        string `json:"code"`
+}
+
+// Fields left out are not changed.
+type RemarkPatch struct {
+	SkillId     *int64  `json:"skill_id,omitempty"`
+	Level       *int64  `json:"level,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Code        *string `json:"code,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+func FromRemark(remark entities.Remark) Remark {
+	return Remark{Id: remark.Id, SkillId: remark.Skill, Level: remark.Level, Description: remark.Description, Code: remark.Code, Archived: remark.Archived}
+}
\ No newline at end of file
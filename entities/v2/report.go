@@ -0,0 +1,38 @@
+package v2
+
+import (
+	"time"
+)
+
+// How a student is doing on every skill they were observed on, and on the
+// framework nodes above those skills.
+type StudentReport struct {
+	Student Student       `json:"student"`
+	Scale   []Outcome     `json:"scale"`
+	Skills  []SkillReport `json:"skills"`
+	Nodes   []NodeReport  `json:"nodes"`
+}
+
+// Counts has the number of observations per outcome code. Latest and Best
+// are outcome codes; MeanScore is null when no observation has a score.
+type OutcomeSummary struct {
+	Observations  int            `json:"observations"`
+	Counts        map[string]int `json:"counts"`
+	Latest        string         `json:"latest"`
+	LatestDate    time.Time      `json:"latest_date"`
+	Best          string         `json:"best"`
+	AchievedRatio float64        `json:"achieved_ratio"`
+	MeanScore     *float64       `json:"mean_score"`
+}
+
+type SkillReport struct {
+	Skill Skill `json:"skill"`
+	OutcomeSummary
+}
+
+// The observations on every skill under Node, in tree order: each node
+// comes before its children.
+type NodeReport struct {
+	Node FrameworkNode `json:"node"`
+	OutcomeSummary
+}
\ No newline at end of file
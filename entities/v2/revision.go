@@ -0,0 +1,59 @@
+package v2
+
+import (
+	"api/entities"
+	"time"
+)
+
+// Old is null in the first revision of an observation.
+type RevisionChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Action is create, update, revert, import or delete, the last revision of
+// a deleted observation. Changes only has the fields that changed, by their
+// name in Observation.
+type ObservationRevision struct {
+	Id            int64                     `json:"id"`
+	ObservationId int64                     `json:"observation_id"`
+	Action        string                    `json:"action"`
+	User          *string                   `json:"user"`
+	ChangedAt     time.Time                 `json:"changed_at"`
+	RevertedFrom  *int64                    `json:"reverted_from"`
+	Changes       map[string]RevisionChange `json:"changes"`
+}
+
+func FromObservationRevision(revision entities.ObservationRevision) ObservationRevision {
+	var previous entities.ObservationRevision
+	if revision.Previous != nil {
+		previous = *revision.Previous
+	}
+	changes := map[string]RevisionChange{}
+	change := func(field string, old any, new any, same bool) {
+		if revision.Previous == nil {
+			changes[field] = RevisionChange{New: new}
+		} else if !same {
+			changes[field] = RevisionChange{Old: old, New: new}
+		}
+	}
+	sameScore := previous.Score == nil && revision.Score == nil ||
+		previous.Score != nil && revision.Score != nil && *previous.Score == *revision.Score
+	change("teacher_id", previous.Teacher, revision.Teacher, previous.Teacher == revision.Teacher)
+	change("student_id", previous.Student, revision.Student, previous.Student == revision.Student)
+	change("remark_id", previous.Remark, revision.Remark, previous.Remark == revision.Remark)
+	change("date", previous.Date, revision.Date, previous.Date.Equal(revision.Date))
+	change("outcome", previous.Outcome, revision.Outcome, previous.Outcome == revision.Outcome)
+	change("score", previous.Score, revision.Score, sameScore)
+	change("notes", previous.Notes, revision.Notes, previous.Notes == revision.Notes)
+
+	return ObservationRevision{
+		Id:            revision.Id,
+		ObservationId: revision.Observation,
+		Action:        revision.Action,
+		User:          revision.User,
+		ChangedAt:     revision.ChangedAt,
+		RevertedFrom:  revision.RevertedFrom,
+		Changes:       changes,
+	}
+}
\ No newline at end of file
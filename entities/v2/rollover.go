@@ -0,0 +1,90 @@
+package v2
+
+import (
+	"api/entities"
+	"time"
+)
+
+// Overrides what happens to one class of the year rolled over. By default a
+// class is promoted to the class named after the next grade, 1A to 2A.
+type RolloverClassInput struct {
+	ClassId  int64   `json:"class_id"`
+	Name     *string `json:"name,omitempty"`
+	Graduate bool    `json:"graduate"`
+}
+
+// Classes whose grade, the first number in their name, is final_grade or
+// more graduate, 0 leaves that to classes. Retained students repeat the
+// year in the class with the same name. With copy_teachers the teachers of
+// a class, and the subjects they teach there, follow it to the class it is
+// promoted to and to the class its retained students repeat the year in.
+// A dry run reports what would happen without changing anything.
+type RolloverInput struct {
+	FromYearId         int64                `json:"from_year_id"`
+	ToYearId           int64                `json:"to_year_id"`
+	Classes            []RolloverClassInput `json:"classes"`
+	FinalGrade         int                  `json:"final_grade"`
+	RetainedStudentIds []int64              `json:"retained_student_ids"`
+	CopyTeachers       bool                 `json:"copy_teachers"`
+	DryRun             bool                 `json:"dry_run"`
+}
+
+// Action is promote, retain or graduate. Class is null for graduates.
+type RolloverClass struct {
+	Action    string `json:"action"`
+	FromClass Class  `json:"from_class"`
+	Class     *Class `json:"class"`
+	Created   bool   `json:"created"`
+}
+
+type RolloverStudent struct {
+	StudentId   int64  `json:"student_id"`
+	Action      string `json:"action"`
+	FromClassId int64  `json:"from_class_id"`
+	ClassId     *int64 `json:"class_id"`
+}
+
+// Dry runs have a null id, the ids of the classes they would create aren't
+// reserved.
+type Rollover struct {
+	Id             *int64            `json:"id"`
+	FromYearId     int64             `json:"from_year_id"`
+	ToYearId       int64             `json:"to_year_id"`
+	User           *string           `json:"user"`
+	PerformedAt    time.Time         `json:"performed_at"`
+	CopyTeachers   bool              `json:"copy_teachers"`
+	UndoUntil      time.Time         `json:"undo_until"`
+	UndoneAt       *time.Time        `json:"undone_at"`
+	Classes        []RolloverClass   `json:"classes"`
+	Students       []RolloverStudent `json:"students"`
+	TeachersCopied int               `json:"teachers_copied"`
+}
+
+func FromRollover(rollover entities.Rollover) Rollover {
+	result := Rollover{
+		FromYearId:     rollover.From,
+		ToYearId:       rollover.To,
+		User:           rollover.User,
+		PerformedAt:    rollover.PerformedAt,
+		CopyTeachers:   rollover.CopyTeachers,
+		UndoUntil:      rollover.UndoUntil,
+		UndoneAt:       rollover.UndoneAt,
+		Classes:        make([]RolloverClass, len(rollover.Classes)),
+		Students:       make([]RolloverStudent, len(rollover.Students)),
+		TeachersCopied: len(rollover.Assignments),
+	}
+	if rollover.Id != 0 {
+		result.Id = &rollover.Id
+	}
+	for i, class := range rollover.Classes {
+		result.Classes[i] = RolloverClass{Action: class.Action, FromClass: FromClass(class.From), Created: class.Created}
+		if class.To != nil {
+			to := FromClass(*class.To)
+			result.Classes[i].Class = &to
+		}
+	}
+	for i, student := range rollover.Students {
+		result.Students[i] = RolloverStudent{StudentId: student.Student, Action: student.Action, FromClassId: student.From, ClassId: student.To}
+	}
+	return result
+}
\ No newline at end of file
@@ -0,0 +1,25 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+// Only the teachers of its subject can observe a skill with a subject_id.
+// node_id is the leaf of the competency framework the skill belongs to.
+type Skill struct {
+	Id        int64  `json:"id"`
+	Name      string `json:"name"`
+	SubjectId *int64 `json:"subject_id"`
+	NodeId    *int64 `json:"node_id"`
+}
+
+// Fields left out are not changed, a subject_id or node_id of 0 clears it.
+type SkillPatch struct {
+	Name      *string `json:"name,omitempty"`
+	SubjectId *int64  `json:"subject_id,omitempty"`
+	NodeId    *int64  `json:"node_id,omitempty"`
+}
+
+func FromSkill(skill entities.Skill) Skill {
+	return Skill{Id: skill.Id, Name: skill.Name, SubjectId: skill.Subject, NodeId: skill.Node}
+}
\ No newline at end of file
@@ -0,0 +1,34 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Student struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Surname string `json:"surname"`
+	Class   Class  `json:"class"`
+	// Graduates stay in the class of their last year
+	GraduatedYearId *int64 `json:"graduated_year_id"`
+}
+
+type StudentInput struct {
+	Name    string `json:"name"`
+	Surname string `json:"surname"`
+	ClassId int64  `json:"class_id"`
+}
+
+// Fields left out are not changed.
+type StudentPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Surname *string `json:"surname,omitempty"`
+	ClassId *int64  `json:"class_id,omitempty"`
+	// The day (YYYY-MM-DD) a change of class_id takes effect, today when
+	// left out. Earlier classes stay in the enrollment history.
+	ClassSince *string `json:"class_since,omitempty"`
+}
+
+func FromStudent(student entities.Student) Student {
+	return Student{Id: student.Id, Name: student.Name, Surname: student.Surname, Class: FromClass(student.Class), GraduatedYearId: student.Graduated}
+}
\ No newline at end of file
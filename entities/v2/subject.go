@@ -0,0 +1,41 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Subject struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type SubjectInput struct {
+	Name string `json:"name"`
+}
+
+// Fields left out are not changed.
+type SubjectPatch struct {
+	Name *string `json:"name,omitempty"`
+}
+
+// The teacher teaches the subject in the class.
+type SubjectAssignment struct {
+	Id        int64 `json:"id"`
+	TeacherId int64 `json:"teacher_id"`
+	ClassId   int64 `json:"class_id"`
+	SubjectId int64 `json:"subject_id"`
+}
+
+type SubjectAssignmentInput struct {
+	TeacherId int64 `json:"teacher_id"`
+	ClassId   int64 `json:"class_id"`
+	SubjectId int64 `json:"subject_id"`
+}
+
+func FromSubject(subject entities.Subject) Subject {
+	return Subject{Id: subject.Id, Name: subject.Name}
+}
+
+func FromSubjectAssignment(assignment entities.SubjectAssignment) SubjectAssignment {
+	return SubjectAssignment{Id: assignment.Id, TeacherId: assignment.Teacher, ClassId: assignment.Class, SubjectId: assignment.Subject}
+}
\ No newline at end of file
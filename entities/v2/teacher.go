@@ -0,0 +1,51 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+type Teacher struct {
+	Id      int64   `json:"id"`
+	Name    string  `json:"name"`
+	Surname string  `json:"surname"`
+	Classes []Class `json:"classes"`
+}
+
+type TeacherInput struct {
+	Name     string  `json:"name"`
+	Surname  string  `json:"surname"`
+	ClassIds []int64 `json:"class_ids"`
+}
+
+// Fields left out are not changed, class_ids replaces the current classes.
+// Classes the teacher keeps keep their role, new ones are lead.
+type TeacherPatch struct {
+	Name     *string  `json:"name,omitempty"`
+	Surname  *string  `json:"surname,omitempty"`
+	ClassIds *[]int64 `json:"class_ids,omitempty"`
+}
+
+// A teacher of a class and their role in it, lead or support.
+type ClassTeacher struct {
+	Id      int64   `json:"id"`
+	Teacher Teacher `json:"teacher"`
+	ClassId int64   `json:"class_id"`
+	Role    string  `json:"role"`
+}
+
+// An empty role is lead.
+type ClassTeacherInput struct {
+	Role string `json:"role"`
+}
+
+func FromTeacher(teacher entities.Teacher) Teacher {
+	classes := make([]Class, len(teacher.Classes))
+	for i, class := range teacher.Classes {
+		classes[i] = FromClass(class)
+	}
+	return Teacher{Id: teacher.Id, Name: teacher.Name, Surname: teacher.Surname, Classes: classes}
+}
+
+func FromClassTeacher(link entities.ClassTeacher) ClassTeacher {
+	return ClassTeacher{Id: link.Id, Teacher: FromTeacher(link.Teacher), ClassId: link.Class, Role: link.Role}
+}
\ No newline at end of file
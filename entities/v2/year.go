@@ -0,0 +1,56 @@
+package v2
+
+import (
+	"api/entities"
+)
+
+// Dates are YYYY-MM-DD, end_date included.
+type SchoolYear struct {
+	Id        int64  `json:"id"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type SchoolYearInput struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// Fields left out are not changed.
+type SchoolYearPatch struct {
+	Name      *string `json:"name,omitempty"`
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+}
+
+type Term struct {
+	Id        int64  `json:"id"`
+	YearId    int64  `json:"year_id"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+type TermInput struct {
+	YearId    int64  `json:"year_id"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// Fields left out are not changed. A term stays in its year.
+type TermPatch struct {
+	Name      *string `json:"name,omitempty"`
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+}
+
+func FromSchoolYear(year entities.SchoolYear) SchoolYear {
+	return SchoolYear{Id: year.Id, Name: year.Name, StartDate: year.Start, EndDate: year.End}
+}
+
+func FromTerm(term entities.Term) Term {
+	return Term{Id: term.Id, YearId: term.Year, Name: term.Name, StartDate: term.Start, EndDate: term.End}
+}
\ No newline at end of file
@@ -0,0 +1,18 @@
+package entities
+
+// Dates are YYYY-MM-DD days in the school's time zone, End included.
+type SchoolYear struct {
+	Id    int64
+	Name  string
+	Start string
+	End   string
+}
+
+// A term lies within its school year.
+type Term struct {
+	Id    int64
+	Year  int64
+	Name  string
+	Start string
+	End   string
+}
\ No newline at end of file
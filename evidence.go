@@ -0,0 +1,318 @@
+package main
+
+import (
+	"api/entities/v2"
+	"bufio"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evidence files are photos of work, audio clips and documents attached to
+// an observation. Their content lives in BLOBS, the evidence table only
+// keeps the metadata, the blob key and the key of a thumbnail.
+
+// Media types accepted as evidence, as sniffed from the content. What the
+// client claims is ignored.
+var EVIDENCE_MEDIA_TYPES = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"audio/aiff":      true,
+	"application/ogg": true,
+	"video/mp4":       true,
+	"application/pdf": true,
+}
+
+// Returns the uploaded file, either the raw request body named by
+// ?filename= or the "file" field of a multipart form, which is streamed
+// rather than buffered.
+func readEvidenceUpload(r *http.Request) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, r.URL.Query().Get("filename"), nil
+	}
+
+	parts, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			return nil, "", invalid("the form has no file field")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() == "file" {
+			return part, part.FileName(), nil
+		}
+	}
+}
+
+func evidenceFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "evidence"
+	}
+	return name
+}
+
+var SHA256_HEX = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Logins tied to a teacher only reach the evidence of their own
+// observations and of students in classes they teach, the class the student
+// was in on the day of the observation. Staff logins reach all of it. Only
+// staff delete evidence.
+func checkEvidenceAccess(q querier, r *http.Request, observationId int64) error {
+	var teacher *int64
+	err := q.QueryRow("SELECT teacher FROM credentials WHERE user = ?", requestUser(r)).Scan(&teacher)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Certificate logins without credentials are kiosks, devices the
+		// school sets up to capture work in class: they attach and show the
+		// evidence of any observation
+		return nil
+	case err != nil:
+		return err
+	case teacher == nil:
+		return nil
+	}
+	var observer, student int64
+	var date time.Time
+	err = q.QueryRow("SELECT teacher, student, date FROM observations WHERE id = ?", observationId).Scan(&observer, &student, &date)
+	if err != nil {
+		return err
+	}
+	if observer == *teacher {
+		return nil
+	}
+	class, err := classOn(q, student, dayOf(date))
+	if err != nil {
+		return err
+	}
+	var allowed bool
+	if class != nil {
+		err = q.QueryRow("SELECT EXISTS (SELECT 1 FROM classes_teachers WHERE teacher_id = ? AND class_id = ?)", *teacher, *class).Scan(&allowed)
+		if err != nil {
+			return err
+		}
+	}
+	if !allowed {
+		return forbidden("the evidence of observation %d is only open to its teacher and the teachers of the student's class on the day", observationId)
+	}
+	return nil
+}
+
+// Loads the evidence named in the path if the user may see it.
+func loadAllowedEvidence(r *http.Request) (Evidence, error) {
+	evidence, err := loadEvidence(DB, r.PathValue("id"))
+	if err != nil {
+		return evidence, err
+	}
+	return evidence, checkEvidenceAccess(DB, r, evidence.Observation)
+}
+
+// Serves a blob with Range support, so audio can be seeked. Blobs never
+// change, so their checksum makes a strong ETag.
+func serveBlob(w http.ResponseWriter, r *http.Request, key string, mediaType string, sum *string, modified time.Time) {
+	content, err := BLOBS.Open(key)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if sum != nil {
+		digest, _ := hex.DecodeString(*sum)
+		w.Header().Set("ETag", `"`+*sum+`"`)
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(digest)+":")
+	}
+	http.ServeContent(w, r, "", modified, content)
+}
+
+func evidenceBlobs(evidence Evidence) []string {
+	if evidence.Thumbnail != nil {
+		return []string{evidence.Blob, *evidence.Thumbnail}
+	}
+	return []string{evidence.Blob}
+}
+
+// Deletes the evidence rows of an observation and returns their blobs, to
+// be released once the transaction is committed.
+func deleteObservationEvidence(tx *sql.Tx, observationId any) ([]string, error) {
+	evidence, err := queryEvidence(tx, "SELECT * FROM evidence WHERE observation = ?", observationId)
+	if err != nil {
+		return nil, err
+	}
+	var blobs []string
+	for _, file := range evidence {
+		blobs = append(blobs, evidenceBlobs(file)...)
+	}
+	_, err = tx.Exec("DELETE FROM evidence WHERE observation = ?", observationId)
+	return blobs, err
+}
+
+func getObservationEvidenceV2(w http.ResponseWriter, r *http.Request) {
+	var observationId int64
+	err := DB.QueryRow("SELECT id FROM observations WHERE id = ?", r.PathValue("id")).Scan(&observationId)
+	if err == nil {
+		err = checkEvidenceAccess(DB, r, observationId)
+	}
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	listV2(w, r, queryEvidence, v2.FromEvidence, "SELECT * FROM evidence WHERE observation = ?", observationId)
+}
+
+// The content is hashed while it is stored. A client sending ?sha256= gets
+// a damaged upload rejected rather than kept.
+func uploadEvidenceV2(w http.ResponseWriter, r *http.Request) {
+	var observationId int64
+	err := DB.QueryRow("SELECT id FROM observations WHERE id = ?", r.PathValue("id")).Scan(&observationId)
+	if err == nil {
+		err = checkEvidenceAccess(DB, r, observationId)
+	}
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	expected := strings.ToLower(r.URL.Query().Get("sha256"))
+	if expected != "" && !SHA256_HEX.MatchString(expected) {
+		errorCheckV2(&w, invalid("sha256 %q isn't 64 hexadecimal digits", expected), 422)
+		return
+	}
+
+	body, filename, err := readEvidenceUpload(r)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	content := bufio.NewReaderSize(body, 512)
+	head, _ := content.Peek(512)
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if len(head) == 0 {
+		errorCheckV2(&w, invalid("the file is empty"), 422)
+		return
+	}
+	if !EVIDENCE_MEDIA_TYPES[mediaType] {
+		errorCheckV2(&w, invalid("files of type %s can't be evidence", mediaType), 422)
+		return
+	}
+
+	BLOB_LOCK.RLock()
+	blob, size, err := BLOBS.Put(io.LimitReader(content, CONFIG.EvidenceMax+1))
+	if err != nil {
+		BLOB_LOCK.RUnlock()
+		errorCheckV2(&w, err, 500)
+		return
+	}
+	var thumbnail string
+	var evidence Evidence
+	switch {
+	case size > CONFIG.EvidenceMax:
+		err = &requestError{status: http.StatusRequestEntityTooLarge, message: "evidence files can be at most " + strconv.FormatInt(CONFIG.EvidenceMax, 10) + " bytes"}
+	case expected != "" && expected != blob:
+		err = invalid("the file doesn't match sha256 %s, it was damaged on the way", expected)
+	default:
+		// A broken image is still evidence, it just gets no thumbnail
+		var thumbErr error
+		thumbnail, thumbErr = makeThumbnail(blob, mediaType)
+		if thumbErr != nil {
+			slog.Warn("Couldn't make thumbnail", "blob", blob, "err", thumbErr)
+		}
+		var thumbnailKey *string
+		if thumbnail != "" {
+			thumbnailKey = &thumbnail
+		}
+		err = withTx(func(tx *sql.Tx) error {
+			// The observation may have been deleted during the upload
+			err := checkExists(tx, "observation", observationId)
+			if err != nil {
+				return err
+			}
+			result, err := tx.Exec("INSERT INTO evidence (observation, filename, media_type, size, blob, uploaded_at, sha256, thumbnail) VALUES(?, ?, ?, ?, ?, ?, ?, ?)", observationId, evidenceFilename(filename), mediaType, size, blob, sqliteTime(time.Now()), blob, thumbnailKey)
+			if err != nil {
+				return err
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			evidence, err = loadEvidence(tx, id)
+			return err
+		})
+	}
+	BLOB_LOCK.RUnlock()
+	if err != nil {
+		releaseBlobs(blob, thumbnail)
+	}
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/evidence/"+strconv.FormatInt(evidence.Id, 10), v2.FromEvidence(evidence))
+}
+
+func getEvidenceV2(w http.ResponseWriter, r *http.Request) {
+	evidence, err := loadAllowedEvidence(r)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Evidence]{Data: v2.FromEvidence(evidence)})
+}
+
+func downloadEvidenceV2(w http.ResponseWriter, r *http.Request) {
+	evidence, err := loadAllowedEvidence(r)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": evidence.Filename}))
+	serveBlob(w, r, evidence.Blob, evidence.MediaType, evidence.Sha256, evidence.UploadedAt)
+}
+
+func downloadThumbnailV2(w http.ResponseWriter, r *http.Request) {
+	evidence, err := loadAllowedEvidence(r)
+	if err == nil && evidence.Thumbnail == nil {
+		err = sql.ErrNoRows
+	}
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	serveBlob(w, r, *evidence.Thumbnail, "image/jpeg", evidence.Thumbnail, evidence.UploadedAt)
+}
+
+func deleteEvidenceV2(w http.ResponseWriter, r *http.Request) {
+	var evidence Evidence
+	err := withTx(func(tx *sql.Tx) error {
+		staff, err := isStaffLogin(tx, r)
+		if err != nil {
+			return err
+		}
+		if !staff {
+			return forbidden("evidence can only be deleted by staff logins")
+		}
+		evidence, err = loadEvidence(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "evidence", evidence.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	releaseBlobs(evidenceBlobs(evidence)...)
+	w.WriteHeader(http.StatusNoContent)
+}
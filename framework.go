@@ -0,0 +1,194 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// The competency framework is a tree of nodes of any depth, key areas
+// holding competencies holding whatever the curriculum needs, with skills
+// on its leaves. Reports add the observations of a node's skills up to the
+// node and every node above it.
+
+// A node and its descendants, the node id as argument. UNION keeps it from
+// looping should a cycle ever get in.
+const FRAMEWORK_SUBTREE = "WITH RECURSIVE subtree(id) AS (SELECT ? UNION SELECT framework_nodes.id FROM framework_nodes JOIN subtree ON framework_nodes.parent = subtree.id) SELECT id FROM subtree"
+
+// Observations on the skills under a node, the node id as argument.
+const UNDER_NODE = "remark IN (SELECT id FROM remarks WHERE skill IN (SELECT id FROM skills WHERE node IN (" + FRAMEWORK_SUBTREE + ")))"
+
+// Skills can only go on nodes without children.
+func checkLeaf(q querier, nodeId int64) error {
+	if err := checkExists(q, "framework node", nodeId); err != nil {
+		return err
+	}
+	var children int
+	err := q.QueryRow("SELECT COUNT(*) FROM framework_nodes WHERE parent = ?", nodeId).Scan(&children)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return conflict("framework node %d has %d child nodes, skills go on leaves", nodeId, children)
+	}
+	return nil
+}
+
+// id is the node being updated, 0 for a new one. Parents must exist, be
+// free of skills and not lie under the node itself; siblings have
+// different names.
+func validateFrameworkNode(q querier, id int64, parent *int64, name string) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if parent != nil {
+		if err := checkExists(q, "framework node", *parent); err != nil {
+			return err
+		}
+		var under bool
+		err := q.QueryRow("SELECT ? IN ("+FRAMEWORK_SUBTREE+")", *parent, id).Scan(&under)
+		if err != nil {
+			return err
+		}
+		if id != 0 && under {
+			return invalid("framework node %d can't go under itself or its descendant %d", id, *parent)
+		}
+		var skills int
+		err = q.QueryRow("SELECT COUNT(*) FROM skills WHERE node = ?", *parent).Scan(&skills)
+		if err != nil {
+			return err
+		}
+		if skills > 0 {
+			return conflict("framework node %d has %d skills, it can't have child nodes", *parent, skills)
+		}
+	}
+	var other int64
+	err := q.QueryRow("SELECT id FROM framework_nodes WHERE id != ? AND parent IS ? AND name = ?", id, parent, name).Scan(&other)
+	if err == nil {
+		return conflict("framework node %d is already called %s", other, name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// ?parent_id= lists the children of a node, 0 the roots.
+func getAllFrameworkNodesV2(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("parent_id")
+	if value == "" {
+		listV2(w, r, queryFrameworkNodes, v2.FromFrameworkNode, "SELECT * FROM framework_nodes")
+		return
+	}
+	parentId, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		errorCheckV2(&w, fmt.Errorf("parent_id must be an integer"), 400)
+		return
+	}
+	if parentId == 0 {
+		listV2(w, r, queryFrameworkNodes, v2.FromFrameworkNode, "SELECT * FROM framework_nodes WHERE parent IS NULL")
+		return
+	}
+	listV2(w, r, queryFrameworkNodes, v2.FromFrameworkNode, "SELECT * FROM framework_nodes WHERE parent = ?", parentId)
+}
+
+func getFrameworkNodeV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadFrameworkNode, v2.FromFrameworkNode)
+}
+
+func createFrameworkNodeV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.FrameworkNodeInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var node FrameworkNode
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateFrameworkNode(tx, 0, input.ParentId, input.Name)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO framework_nodes (parent, name, code) VALUES(?, ?, ?)", input.ParentId, input.Name, input.Code)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		node, err = loadFrameworkNode(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/framework/nodes/"+strconv.FormatInt(node.Id, 10), v2.FromFrameworkNode(node))
+}
+
+func updateFrameworkNodeV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.FrameworkNodePatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var node FrameworkNode
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		node, err = loadFrameworkNode(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		patchField(&node.Name, patch.Name)
+		patchField(&node.Code, patch.Code)
+		switch {
+		case patch.ParentId == nil:
+		case *patch.ParentId == 0:
+			node.Parent = nil
+		default:
+			node.Parent = patch.ParentId
+		}
+		err = validateFrameworkNode(tx, node.Id, node.Parent, node.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE framework_nodes SET parent = ?, name = ?, code = ? WHERE id = ?", node.Parent, node.Name, node.Code, node.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.FrameworkNode]{Data: v2.FromFrameworkNode(node)})
+}
+
+// Only nodes without children or skills can be deleted.
+func deleteFrameworkNodeV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		node, err := loadFrameworkNode(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		for _, use := range []struct{ table, column, noun string }{
+			{"framework_nodes", "parent", "child nodes"},
+			{"skills", "node", "skills"},
+		} {
+			var count int
+			err := tx.QueryRow("SELECT COUNT(*) FROM "+use.table+" WHERE "+use.column+" = ?", node.Id).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return conflict("framework node %d still has %d %s, move or delete them first", node.Id, count, use.noun)
+			}
+		}
+		return deleteRow(tx, "framework_nodes", node.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+var STARTED = time.Now()
+
+type HealthCheck struct {
+	Status string
+	Detail string `json:",omitempty"`
+}
+
+type HealthReport struct {
+	Status string
+	Checks map[string]HealthCheck
+}
+
+// A failing check returns an error; detail is only shown in verbose mode.
+type healthCheckFunc func(ctx context.Context) (detail string, err error)
+
+func checkDatabase(ctx context.Context) (string, error) {
+	err := DB.PingContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	var one int
+	err = DB.QueryRowContext(ctx, "SELECT 1 FROM sqlite_master LIMIT 1").Scan(&one)
+	if err != nil {
+		return "", err
+	}
+	return CONFIG.DatabasePath, nil
+}
+
+func checkSchema(ctx context.Context) (string, error) {
+	var version int
+	err := DB.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return "", err
+	}
+	if version != SCHEMA_VERSION {
+		return "", fmt.Errorf("database is at schema version %d, binary expects %d", version, SCHEMA_VERSION)
+	}
+	return fmt.Sprintf("version %d", version), nil
+}
+
+func checkDisk(ctx context.Context) (string, error) {
+	free, err := freeDiskBytes(filepath.Dir(CONFIG.DatabasePath))
+	if errors.Is(err, errors.ErrUnsupported) {
+		return "not measurable on this platform", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	detail := fmt.Sprintf("%d MiB free", free>>20)
+	if free < CONFIG.MinFreeDisk {
+		return detail, fmt.Errorf("only %d MiB free, need %d MiB", free>>20, CONFIG.MinFreeDisk>>20)
+	}
+	return detail, nil
+}
+
+func checkProcess(ctx context.Context) (string, error) {
+	return fmt.Sprintf("up %s, %d goroutines", time.Since(STARTED).Round(time.Second), runtime.NumGoroutine()), nil
+}
+
+// Runs checks and answers 200 when all pass, 503 otherwise. ?verbose=true
+// adds details and error messages, and needs the same credentials as the API.
+func healthHandler(checks map[string]healthCheckFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verbose := r.URL.Query().Get("verbose") == "true"
+		if verbose {
+			user, pass, _ := r.BasicAuth()
+			if _, ok := clientCertUser(r); !ok && !checkCredentials(user, pass) {
+				w.Header().Set("WWW-Authenticate", "Basic realm=\"Svalutation\"")
+				http.Error(w, "Authentication failed, you shall not pass", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		report := HealthReport{Status: "ok", Checks: map[string]HealthCheck{}}
+		for name, check := range checks {
+			detail, err := check(ctx)
+			result := HealthCheck{Status: "ok"}
+			if err != nil {
+				result.Status = "fail"
+				report.Status = "fail"
+				detail = err.Error()
+			}
+			if verbose {
+				result.Detail = detail
+			}
+			report.Checks[name] = result
+		}
+
+		code := http.StatusOK
+		if report.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+var livenessCheck = healthHandler(map[string]healthCheckFunc{
+	"process": checkProcess,
+})
+
+var readinessCheck = healthHandler(map[string]healthCheckFunc{
+	"database": checkDatabase,
+	"schema":   checkSchema,
+	"disk":     checkDisk,
+})
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+type contextKey int
+
+const requestInfoKey contextKey = iota
+
+// Per-request facts the access log needs from deeper handlers, like the
+// user auth let through.
+type requestInfo struct {
+	id   string
+	user string
+}
+
+func newLogHandler(format string, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.NewJSONHandler(os.Stderr, options)
+	}
+	return slog.NewTextHandler(os.Stderr, options)
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Accept a caller's id only if it is short and plain enough to log verbatim.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func setRequestUser(r *http.Request, user string) {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		info.user = user
+	}
+}
+
+// Returns the user auth let through, empty outside authenticated routes.
+func requestUser(r *http.Request) string {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		return info.user
+	}
+	return ""
+}
+
+// Tags every request with an X-Request-ID, propagated from the caller or
+// generated, and logs one line per request once it is served.
+func accessLog(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{id: r.Header.Get(REQUEST_ID_HEADER)}
+		if !validRequestId(info.id) {
+			info.id = newRequestId()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, info.id)
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+
+		_, route := mux.Handler(r)
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		slog.Info("Request",
+			"request_id", info.id,
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration", time.Since(start),
+			"user", info.user,
+			"remote", r.RemoteAddr,
+		)
+	})
+}
@@ -4,9 +4,15 @@ import (
 	"api/entities"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
@@ -14,15 +20,29 @@ import (
 
 type Student = entities.Student
 type Teacher = entities.Teacher
+type ClassTeacher = entities.ClassTeacher
 type Remark = entities.Remark
 type Observation = entities.Observation
 type Class = entities.Class
-
-var DB, DB_ERR = sql.Open("sqlite3", "./database.db")
-var IGNORE sql.Null[any]
+type Skill = entities.Skill
+type FrameworkNode = entities.FrameworkNode
+type Subject = entities.Subject
+type SubjectAssignment = entities.SubjectAssignment
+type Outcome = entities.Outcome
+type Evidence = entities.Evidence
+type ObservationRevision = entities.ObservationRevision
+type SchoolYear = entities.SchoolYear
+type Term = entities.Term
+type Enrollment = entities.Enrollment
+type Rollover = entities.Rollover
+type RolloverClass = entities.RolloverClass
+type RolloverStudent = entities.RolloverStudent
+
+var DB *sql.DB
 
 func errorCheck(w *http.ResponseWriter, err error, code int) bool {
 	if err != nil {
+		slog.Error("Request failed", "request_id", (*w).Header().Get(REQUEST_ID_HEADER), "status", code, "err", err)
 		http.Error(*w, err.Error(), code)
 		return true
 	}
@@ -30,23 +50,14 @@ func errorCheck(w *http.ResponseWriter, err error, code int) bool {
 }
 
 func getAllStudents(w http.ResponseWriter, r *http.Request) {
-	rows, err := DB.Query("SELECT * FROM students")
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := periodFilter(DB, r, "students", nil, nil)
+	if errorCheck(&w, err, 400) {
 		return
 	}
-
-	var students []Student
-	for rows.Next() {
-		var student Student
-		rows.Scan(&student.Id, &student.Name, &student.Surname, &student.Class.Id)
-		err := DB.QueryRow("SELECT * FROM classes WHERE id = ?", student.Class.Id).Scan(&student.Class.Id, &student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-
-		students = append(students, student)
+	students, err := queryStudents(DB, selectWhere("students", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -59,11 +70,18 @@ func createStudent(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 400) {
 		return
 	}
-	result, err := DB.Exec("INSERT INTO students (name, surname, class) VALUES(?, ?, ?)", r.Form.Get("name"), r.Form.Get("surname"), r.Form.Get("class"))
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	id, err := result.LastInsertId()
+	var id int64
+	err = withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("INSERT INTO students (name, surname, class) VALUES(?, ?, ?)", r.Form.Get("name"), r.Form.Get("surname"), r.Form.Get("class"))
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return enrollStudent(tx, id, r.Form.Get("class"))
+	})
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -76,12 +94,7 @@ func createStudent(w http.ResponseWriter, r *http.Request) {
 func getStudent(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	var student Student
-	err := DB.QueryRow("SELECT * FROM students WHERE id = ?", id).Scan(&student.Id, &student.Name, &student.Surname, &student.Class.Id)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", student.Class.Id).Scan(&student.Class.Id, &student.Class.Name)
+	student, err := loadStudent(DB, id)
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -118,7 +131,21 @@ func updateStudent(w http.ResponseWriter, r *http.Request) {
 
 	var form_class string = r.Form.Get("class")
 	if form_class != "" {
-		_, err = DB.Exec("UPDATE students SET class = ? WHERE id = ?", form_class, id)
+		class, err := strconv.ParseInt(form_class, 10, 64)
+		if errorCheck(&w, err, 400) {
+			return
+		}
+		err = withTx(func(tx *sql.Tx) error {
+			// Like the other fields, a missing student is left alone
+			student, err := loadStudent(tx, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return moveStudent(tx, student.Id, class, today())
+		})
 		if errorCheck(&w, err, 500) {
 			return
 		}
@@ -133,7 +160,14 @@ func deleteStudent(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 400) {
 		return
 	}
-	_, err = DB.Exec("DELETE FROM students WHERE id = ?", id)
+	err = withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM enrollments WHERE student = ?", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM students WHERE id = ?", id)
+		return err
+	})
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -143,23 +177,18 @@ func deleteStudent(w http.ResponseWriter, r *http.Request) {
 func getStudentsByClass(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	rows, err := DB.Query("SELECT * FROM students WHERE class = ?", id)
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := asOfFilter(r, id, nil, nil)
+	if errorCheck(&w, err, 400) {
 		return
 	}
-
-	var students []Student
-	for rows.Next() {
-		var student Student
-		rows.Scan(&student.Id, &student.Name, &student.Surname, &student.Class.Id)
-		err := DB.QueryRow("SELECT * FROM classes where id = ?", student.Class.Id).Scan(&student.Class.Id, &student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-
-		students = append(students, student)
+	conditions, args, err = periodFilter(DB, r, "students", conditions, args)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	students, err := queryStudents(DB, selectWhere("students", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -168,39 +197,14 @@ func getStudentsByClass(w http.ResponseWriter, r *http.Request) {
 }
 
 func getAllTeachers(w http.ResponseWriter, r *http.Request) {
-	rows, err := DB.Query("SELECT * FROM teachers")
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := periodFilter(DB, r, "teachers", nil, nil)
+	if errorCheck(&w, err, 400) {
 		return
 	}
-
-	var teachers []Teacher
-	for rows.Next() {
-		var teacher Teacher
-		rows.Scan(&teacher.Id, &teacher.Name, &teacher.Surname)
-
-		rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", teacher.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-
-		var classes []Class
-		for rows.Next() {
-			var class Class
-			rows.Scan(&IGNORE, &IGNORE, &class.Id)
-
-			err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-			if errorCheck(&w, err, 500) {
-				return
-			}
-
-			classes = append(classes, class)
-		}
-		rows.Close()
-		teacher.Classes = classes
-
-		teachers = append(teachers, teacher)
+	teachers, err := queryTeachers(DB, selectWhere("teachers", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -229,11 +233,11 @@ func createTeacher(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, element := range classIds {
-		_, err := DB.Exec("INSERT INTO classes_teachers (teacher_id, class_id) VALUES(?, ?)", id, element)
-		if errorCheck(&w, err, 500) {
-			return
-		}
+	err = withTx(func(tx *sql.Tx) error {
+		return setTeacherClasses(tx, id, classIds)
+	})
+	if errorCheck(&w, err, 500) {
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -245,28 +249,10 @@ func createTeacher(w http.ResponseWriter, r *http.Request) {
 func getTeacher(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	var teacher Teacher
-	err := DB.QueryRow("SELECT * FROM teachers WHERE id = ?", id).Scan(&teacher.Id, &teacher.Name, &teacher.Surname)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-
-	rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", teacher.Id)
+	teacher, err := loadTeacher(DB, id)
 	if errorCheck(&w, err, 500) {
 		return
 	}
-	var classes []Class
-	for rows.Next() {
-		var class Class
-		rows.Scan(&IGNORE, &IGNORE, &class.Id)
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		classes = append(classes, class)
-	}
-	rows.Close()
-	teacher.Classes = classes
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -298,10 +284,11 @@ func updateTeacher(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// An empty [] unlinks the teacher from every class
 	var form_classes string = r.Form.Get("classes")
-	if form_classes != "" && form_classes != "[]" {
-		_, err := DB.Exec("DELETE FROM classes_teachers WHERE teacher_id = ?", id)
-		if errorCheck(&w, err, 500) {
+	if form_classes != "" {
+		teacherId, err := strconv.ParseInt(id, 10, 64)
+		if errorCheck(&w, err, 400) {
 			return
 		}
 
@@ -311,11 +298,11 @@ func updateTeacher(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		for _, element := range classIds {
-			_, err := DB.Exec("INSERT INTO classes_teachers (teacher_id, class_id) VALUES(?, ?)", id, element)
-			if errorCheck(&w, err, 500) {
-				return
-			}
+		err = withTx(func(tx *sql.Tx) error {
+			return setTeacherClasses(tx, teacherId, classIds)
+		})
+		if errorCheck(&w, err, 500) {
+			return
 		}
 	}
 	return
@@ -336,24 +323,24 @@ func deleteTeacher(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 500) {
 		return
 	}
+	_, err = DB.Exec("DELETE FROM teacher_subjects WHERE teacher = ?", id)
+	if errorCheck(&w, err, 500) {
+		return
+	}
 
 	return
 }
 
 func getAllRemarks(w http.ResponseWriter, r *http.Request) {
-	rows, err := DB.Query("SELECT * FROM remarks")
+	query := "SELECT * FROM remarks WHERE archived = 0"
+	if r.URL.Query().Get("archived") == "true" {
+		query = "SELECT * FROM remarks"
+	}
+	remarks, err := queryRemarks(DB, query)
 	if errorCheck(&w, err, 500) {
 		return
 	}
 
-	var remarks []Remark
-	for rows.Next() {
-		var remark Remark
-		rows.Scan(&remark.Id, &remark.Skill, &remark.Level, &remark.Description)
-		remarks = append(remarks, remark)
-	}
-	rows.Close()
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(remarks)
@@ -365,11 +352,26 @@ func createRemark(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 400) {
 		return
 	}
-	result, err := DB.Exec("INSERT INTO remarks (skill, level, description) VALUES(?, ?, ?)", r.Form.Get("skill"), r.Form.Get("level"), r.Form.Get("description"))
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	id, err := result.LastInsertId()
+	var id int64
+	err = withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT OR IGNORE INTO skills (id) VALUES(?)", r.Form.Get("skill"))
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO remarks (skill, level, description, code) VALUES(?, ?, ?, ?)", r.Form.Get("skill"), r.Form.Get("level"), r.Form.Get("description"), r.Form.Get("code"))
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		if err != nil || r.Form.Get("code") != "" {
+			return err
+		}
+		// Remarks without a code get their id as one, like the migration
+		// gave the ones from before codes, so they don't all share the code
+		// '' in the natural key
+		_, err = tx.Exec("UPDATE remarks SET code = ? WHERE id = ?", strconv.FormatInt(id, 10), id)
+		return err
+	})
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -382,8 +384,7 @@ func createRemark(w http.ResponseWriter, r *http.Request) {
 func getRemark(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	var remark Remark
-	err := DB.QueryRow("SELECT * FROM remarks WHERE id = ?", id).Scan(&remark.Id, &remark.Skill, &remark.Level, &remark.Description)
+	remark, err := loadRemark(DB, id)
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -404,6 +405,10 @@ func updateRemark(w http.ResponseWriter, r *http.Request) {
 
 	var form_skill string = r.Form.Get("skill")
 	if form_skill != "" {
+		_, err = DB.Exec("INSERT OR IGNORE INTO skills (id) VALUES(?)", form_skill)
+		if errorCheck(&w, err, 500) {
+			return
+		}
 		_, err = DB.Exec("UPDATE remarks SET skill = ? WHERE id = ?", form_skill, id)
 		if errorCheck(&w, err, 500) {
 			return
@@ -425,6 +430,22 @@ func updateRemark(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+
+	var form_code string = r.Form.Get("code")
+	if form_code != "" {
+		_, err = DB.Exec("UPDATE remarks SET code = ? WHERE id = ?", form_code, id)
+		if errorCheck(&w, err, 500) {
+			return
+		}
+	}
+
+	var form_archived string = r.Form.Get("archived")
+	if form_archived != "" {
+		_, err = DB.Exec("UPDATE remarks SET archived = ? WHERE id = ?", form_archived == "true", id)
+		if errorCheck(&w, err, 500) {
+			return
+		}
+	}
 	return
 }
 
@@ -442,53 +463,45 @@ func deleteRemark(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func getAllObservations(w http.ResponseWriter, r *http.Request) {
-	rows, err := DB.Query("SELECT * FROM observations")
+func getAllSkills(w http.ResponseWriter, r *http.Request) {
+	skills, err := querySkills(DB, "SELECT * FROM skills")
 	if errorCheck(&w, err, 500) {
 		return
 	}
 
-	var observations []Observation
-	for rows.Next() {
-		var observation Observation
-		rows.Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Achieved, &observation.Date)
-		err = DB.QueryRow("SELECT * FROM teachers WHERE id = ?", observation.Teacher.Id).Scan(&observation.Teacher.Id, &observation.Teacher.Name, &observation.Teacher.Surname)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", observation.Teacher.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		var classes []Class
-		for rows.Next() {
-			var class Class
-			rows.Scan(&IGNORE, &IGNORE, &class.Id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(skills)
+	return
+}
 
-			err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-			if errorCheck(&w, err, 500) {
-				return
-			}
-			classes = append(classes, class)
-		}
-		rows.Close()
-		observation.Teacher.Classes = classes
+func updateSkill(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
 
-		err = DB.QueryRow("SELECT * FROM students WHERE id = ?", observation.Student.Id).Scan(&observation.Student.Id, &observation.Student.Name, &observation.Student.Surname, &observation.Student.Class.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", observation.Student.Class.Id).Scan(&observation.Student.Class.Id, &observation.Student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM remarks WHERE id = ?", observation.Remark.Id).Scan(&observation.Remark.Id, &observation.Remark.Skill, &observation.Remark.Level, &observation.Remark.Description)
+	err := r.ParseForm()
+	if errorCheck(&w, err, 400) {
+		return
+	}
+
+	var form_name string = r.Form.Get("name")
+	if form_name != "" {
+		_, err = DB.Exec("UPDATE skills SET name = ? WHERE id = ?", form_name, id)
 		if errorCheck(&w, err, 500) {
 			return
 		}
-		observations = append(observations, observation)
 	}
-	rows.Close()
+	return
+}
+
+func getAllObservations(w http.ResponseWriter, r *http.Request) {
+	conditions, args, err := periodFilter(DB, r, "observations", nil, nil)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	observations, err := queryObservations(DB, selectWhere("observations", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -501,11 +514,39 @@ func createObservation(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 400) {
 		return
 	}
-	result, err := DB.Exec("INSERT INTO observations (teacher, student, remark, achieved) VALUES(?, ?, ?, ?)", r.Form.Get("teacher"), r.Form.Get("student"), r.Form.Get("remark"), r.Form.Get("achieved"))
+	now := time.Now()
+	date := now
+	if r.Form.Get("date") != "" {
+		date, err = parseObservationDate(r.Form.Get("date"))
+		if errorCheck(&w, err, 400) {
+			return
+		}
+	}
+	err = validateObservationDate(DB, date)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	err = checkTeaches(DB, r.Form.Get("teacher"), r.Form.Get("student"), r.Form.Get("remark"), date)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	achieved, _ := strconv.ParseBool(r.Form.Get("achieved"))
+	outcome, err := outcomeForAchieved(DB, achieved)
 	if errorCheck(&w, err, 500) {
 		return
 	}
-	id, err := result.LastInsertId()
+	var id int64
+	err = withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("INSERT INTO observations (teacher, student, remark, outcome, date, recorded_at) VALUES(?, ?, ?, ?, ?, ?)", r.Form.Get("teacher"), r.Form.Get("student"), r.Form.Get("remark"), outcome, sqliteTime(date), sqliteTime(now))
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx, requestUser(r), id, "create", nil)
+	})
 	if errorCheck(&w, err, 500) {
 		return
 	}
@@ -518,44 +559,11 @@ func createObservation(w http.ResponseWriter, r *http.Request) {
 func getObservation(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	var observation Observation
-	err := DB.QueryRow("SELECT * FROM observations WHERE id = ?", id).Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Achieved, &observation.Date)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", observation.Teacher.Id)
+	observation, err := loadObservation(DB, id)
 	if errorCheck(&w, err, 500) {
 		return
 	}
-	var classes []Class
-	for rows.Next() {
-		var class Class
-		rows.Scan(&IGNORE, &IGNORE, &class.Id)
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		classes = append(classes, class)
-	}
-	rows.Close()
-	observation.Teacher.Classes = classes
 
-	err = DB.QueryRow("SELECT * FROM teachers WHERE id = ?", observation.Teacher.Id).Scan(&observation.Teacher.Id, &observation.Teacher.Name, &observation.Teacher.Surname)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	err = DB.QueryRow("SELECT * FROM students WHERE id = ?", observation.Student.Id).Scan(&observation.Student.Id, &observation.Student.Name, &observation.Student.Surname, &observation.Student.Class.Id)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", observation.Student.Class.Id).Scan(&observation.Student.Class.Id, &observation.Student.Class.Name)
-	if errorCheck(&w, err, 500) {
-		return
-	}
-	err = DB.QueryRow("SELECT * FROM remarks WHERE id = ?", observation.Remark.Id).Scan(&observation.Remark.Id, &observation.Remark.Skill, &observation.Remark.Level, &observation.Remark.Description)
-	if errorCheck(&w, err, 500) {
-		return
-	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(observation)
@@ -570,37 +578,83 @@ func updateObservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var form_teacher string = r.Form.Get("teacher")
-	if form_teacher != "" {
-		_, err = DB.Exec("UPDATE observations SET teacher = ? WHERE id = ?", form_teacher, id)
-		if errorCheck(&w, err, 500) {
+	var date time.Time
+	if r.Form.Get("date") != "" {
+		date, err = parseObservationDate(r.Form.Get("date"))
+		if errorCheck(&w, err, 400) {
 			return
 		}
 	}
+	// Like the other fields, a missing observation is left alone
+	current, err := loadObservation(DB, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if errorCheck(&w, err, 500) {
+		return
+	}
 
-	var form_student string = r.Form.Get("student")
-	if form_student != "" {
-		_, err = DB.Exec("UPDATE observations SET student = ? WHERE id = ?", form_student, id)
-		if errorCheck(&w, err, 500) {
+	// Changing who, on whom, what or when passes the checks of
+	// createObservation again
+	ids := []string{strconv.FormatInt(current.Teacher.Id, 10), strconv.FormatInt(current.Student.Id, 10), strconv.FormatInt(current.Remark.Id, 10)}
+	changed := false
+	for i, field := range []string{"teacher", "student", "remark"} {
+		if value := r.Form.Get(field); value != "" && value != ids[i] {
+			ids[i], changed = value, true
+		}
+	}
+	if date.IsZero() {
+		date = current.Date
+	} else {
+		err = validateObservationDate(DB, date)
+		if errorCheck(&w, err, 400) {
 			return
 		}
+		changed = changed || !date.Equal(current.Date)
 	}
-
-	var form_remark string = r.Form.Get("remark")
-	if form_remark != "" {
-		_, err = DB.Exec("UPDATE observations SET remark = ? WHERE id = ?", form_remark, id)
-		if errorCheck(&w, err, 500) {
+	if changed {
+		err = checkTeaches(DB, ids[0], ids[1], ids[2], date)
+		if errorCheck(&w, err, 400) {
 			return
 		}
 	}
-
 	var form_achieved string = r.Form.Get("achieved")
+	achieved, _ := strconv.ParseBool(form_achieved)
+	var outcome string
 	if form_achieved != "" {
-		_, err = DB.Exec("UPDATE observations SET achieved = ? WHERE id = ?", form_achieved, id)
+		outcome, err = outcomeForAchieved(DB, achieved)
 		if errorCheck(&w, err, 500) {
 			return
 		}
 	}
+
+	err = withTx(func(tx *sql.Tx) error {
+		for _, field := range []string{"teacher", "student", "remark"} {
+			if value := r.Form.Get(field); value != "" {
+				_, err := tx.Exec("UPDATE observations SET "+field+" = ? WHERE id = ?", value, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if r.Form.Get("date") != "" {
+			_, err := tx.Exec("UPDATE observations SET date = ? WHERE id = ?", sqliteTime(date), id)
+			if err != nil {
+				return err
+			}
+		}
+		// Outcomes that already agree with achieved are kept
+		if form_achieved != "" {
+			_, err := tx.Exec("UPDATE observations SET outcome = ? WHERE id = ? AND outcome NOT IN (SELECT code FROM outcomes WHERE achieved = ?)", outcome, id, achieved)
+			if err != nil {
+				return err
+			}
+		}
+		return recordRevision(tx, requestUser(r), id, "update", nil)
+	})
+	if errorCheck(&w, err, 500) {
+		return
+	}
 	return
 }
 
@@ -611,61 +665,37 @@ func deleteObservation(w http.ResponseWriter, r *http.Request) {
 	if errorCheck(&w, err, 400) {
 		return
 	}
-	_, err = DB.Exec("DELETE FROM observations WHERE id = ?", id)
+	var blobs []string
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		blobs, err = deleteObservationEvidence(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(tx, requestUser(r), id, "delete", nil); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM observations WHERE id = ?", id)
+		return err
+	})
 	if errorCheck(&w, err, 500) {
 		return
 	}
+	releaseBlobs(blobs...)
 	return
 }
 
 func getObservationsOnStudent(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	rows, err := DB.Query("SELECT * FROM observations where student = ?", id)
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := periodFilter(DB, r, "observations", []string{"student = ?"}, []any{id})
+	if errorCheck(&w, err, 400) {
 		return
 	}
-	var observations []Observation
-	for rows.Next() {
-		var observation Observation
-		rows.Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Achieved, &observation.Date)
-		rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", observation.Teacher.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-
-		var classes []Class
-		for rows.Next() {
-			var class Class
-			rows.Scan(&IGNORE, &IGNORE, &class.Id)
-			err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-			if errorCheck(&w, err, 500) {
-				return
-			}
-			classes = append(classes, class)
-		}
-		rows.Close()
-		observation.Teacher.Classes = classes
-
-		err = DB.QueryRow("SELECT * FROM teachers WHERE id = ?", observation.Teacher.Id).Scan(&observation.Teacher.Id, &observation.Teacher.Name, &observation.Teacher.Surname)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM students WHERE id = ?", observation.Student.Id).Scan(&observation.Student.Id, &observation.Student.Name, &observation.Student.Surname, &observation.Student.Class.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", observation.Student.Class.Id).Scan(&observation.Student.Class.Id, &observation.Student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM remarks WHERE id = ?", observation.Remark.Id).Scan(&observation.Remark.Id, &observation.Remark.Skill, &observation.Remark.Level, &observation.Remark.Description)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		observations = append(observations, observation)
+	observations, err := queryObservations(DB, selectWhere("observations", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -676,51 +706,14 @@ func getObservationsOnStudent(w http.ResponseWriter, r *http.Request) {
 func getObservationsByTeacher(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	rows, err := DB.Query("SELECT * FROM observations where teacher = ?", id)
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := periodFilter(DB, r, "observations", []string{"teacher = ?"}, []any{id})
+	if errorCheck(&w, err, 400) {
 		return
 	}
-	var observations []Observation
-	for rows.Next() {
-		var observation Observation
-		rows.Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Achieved, &observation.Date)
-		rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", observation.Teacher.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-
-		var classes []Class
-		for rows.Next() {
-			var class Class
-			rows.Scan(&IGNORE, &IGNORE, &class.Id)
-			err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-			if errorCheck(&w, err, 500) {
-				return
-			}
-			classes = append(classes, class)
-		}
-		rows.Close()
-		observation.Teacher.Classes = classes
-
-		err = DB.QueryRow("SELECT * FROM teachers WHERE id = ?", observation.Teacher.Id).Scan(&observation.Teacher.Id, &observation.Teacher.Name, &observation.Teacher.Surname)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM students WHERE id = ?", observation.Student.Id).Scan(&observation.Student.Id, &observation.Student.Name, &observation.Student.Surname, &observation.Student.Class.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", observation.Student.Class.Id).Scan(&observation.Student.Class.Id, &observation.Student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM remarks WHERE id = ?", observation.Remark.Id).Scan(&observation.Remark.Id, &observation.Remark.Skill, &observation.Remark.Level, &observation.Remark.Description)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		observations = append(observations, observation)
+	observations, err := queryObservations(DB, selectWhere("observations", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -732,51 +725,14 @@ func getObservationsByTeacherOnStudent(w http.ResponseWriter, r *http.Request) {
 	teacherId := r.PathValue("teacherId")
 	studentId := r.PathValue("studentId")
 
-	rows, err := DB.Query("SELECT * FROM observations where teacher = ? and student = ?", teacherId, studentId)
-	if errorCheck(&w, err, 500) {
+	conditions, args, err := periodFilter(DB, r, "observations", []string{"teacher = ?", "student = ?"}, []any{teacherId, studentId})
+	if errorCheck(&w, err, 400) {
 		return
 	}
-
-	var observations []Observation
-	for rows.Next() {
-		var observation Observation
-		rows.Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Achieved, &observation.Date)
-		rows, err := DB.Query("SELECT * FROM classes_teachers WHERE teacher_id = ?", observation.Teacher.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		var classes []Class
-		for rows.Next() {
-			var class Class
-			rows.Scan(&IGNORE, &IGNORE, &class.Id)
-			err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", class.Id).Scan(&class.Id, &class.Name)
-			if errorCheck(&w, err, 500) {
-				return
-			}
-			classes = append(classes, class)
-		}
-		rows.Close()
-		observation.Teacher.Classes = classes
-
-		err = DB.QueryRow("SELECT * FROM teachers WHERE id = ?", observation.Teacher.Id).Scan(&observation.Teacher.Id, &observation.Teacher.Name, &observation.Teacher.Surname)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM students WHERE id = ?", observation.Student.Id).Scan(&observation.Student.Id, &observation.Student.Name, &observation.Student.Surname, &observation.Student.Class.Id)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM classes WHERE id = ?", observation.Student.Class.Id).Scan(&observation.Student.Class.Id, &observation.Student.Class.Name)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		err = DB.QueryRow("SELECT * FROM remarks WHERE id = ?", observation.Remark.Id).Scan(&observation.Remark.Id, &observation.Remark.Skill, &observation.Remark.Level, &observation.Remark.Description)
-		if errorCheck(&w, err, 500) {
-			return
-		}
-		observations = append(observations, observation)
+	observations, err := queryObservations(DB, selectWhere("observations", conditions), args...)
+	if errorCheck(&w, err, 500) {
+		return
 	}
-	rows.Close()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -784,24 +740,27 @@ func getObservationsByTeacherOnStudent(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func enableCors(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "http://85.235.150.118:3000")
-	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
-	(*w).Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-	(*w).Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-}
-
 func auth(fn http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user, pass, _ := r.BasicAuth()
+		// Kiosks authenticate with a client certificate instead of a password
+		if user, ok := clientCertUser(r); ok {
+			setRequestUser(r, user)
+			fn(w, r)
+			return
+		}
 
-		enableCors(&w)
+		user, pass, _ := r.BasicAuth()
 
 		if !checkCredentials(user, pass) {
 			w.Header().Set("WWW-Authenticate", "Basic realm=\"Svalutation\"")
+			if isV2(r) {
+				writeV2Error(w, http.StatusUnauthorized, "Authentication failed, you shall not pass")
+				return
+			}
 			http.Error(w, "Authentication failed, you shall not pass", http.StatusUnauthorized)
 			return
 		}
+		setRequestUser(r, user)
 		fn(w, r)
 	}
 }
@@ -810,35 +769,66 @@ func checkCredentials(user string, password string) bool {
 	type Credentials struct {
 		user     string
 		password string
+		teacher  *int64
 	}
 	credentials := Credentials{}
 
-	err := DB.QueryRow("SELECT * FROM credentials WHERE user = ?", user).Scan(&credentials.user, &credentials.password)
+	err := DB.QueryRow("SELECT * FROM credentials WHERE user = ?", user).Scan(&credentials.user, &credentials.password, &credentials.teacher)
 	if err != nil {
-		slog.Error("Couldn't retrieve credentials") // TODO: Manage this kind of error, it could mean the username the user provided is wrong
+		slog.Warn("Couldn't retrieve credentials", "user", user, "err", err) // TODO: Manage this kind of error, it could mean the username the user provided is wrong
 	}
-	return bcrypt.CompareHashAndPassword([]byte(credentials.password), []byte(password)) == nil
+	if sessionValid(user, credentials.password, password) {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(credentials.password), []byte(password)) != nil {
+		AUTH_FAILURES.inc()
+		return false
+	}
+	rememberSession(user, credentials.password, password)
+	return true
 }
 
 func statusCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func main() {
-	if DB_ERR != nil {
-		log.Fatal(DB_ERR)
+func serve() error {
+	err := migrate(DB)
+	if err != nil {
+		return err
 	}
-	defer DB.Close()
 
 	slog.Info("Loaded database")
 
-	mux := http.NewServeMux()
+	if CONFIG.BackupInterval.Duration > 0 {
+		go scheduleBackups(DB, CONFIG.BackupDir, CONFIG.BackupInterval.Duration, CONFIG.BackupKeep)
+	}
+	if CONFIG.BlobGCInterval.Duration > 0 {
+		go scheduleBlobGC(CONFIG.BlobGCInterval.Duration)
+	}
 
-	// CORS OPTIONS handler
-	mux.HandleFunc("OPTIONS /", func(w http.ResponseWriter, r *http.Request) { enableCors(&w); w.WriteHeader(http.StatusOK) })
+	return listen(newHandler())
+}
 
-	// Status handler
+// The routes of newMux behind the middleware every request goes through.
+func newHandler() http.Handler {
+	router := newMux()
+	mux := router.ServeMux
+	return accessLog(mux, instrument(mux, deprecateV1(router, cors(mux))))
+}
+
+func newMux() *router {
+	mux := newRouter()
+
+	// Status handlers
 	mux.HandleFunc("GET /status", statusCheck)
+	mux.HandleFunc("GET /healthz", livenessCheck)
+	mux.HandleFunc("GET /readyz", readinessCheck)
+	mux.HandleFunc("GET /metrics", getMetrics)
+
+	// Documentation handlers
+	mux.HandleFunc("GET /openapi.json", getOpenAPI)
+	mux.HandleFunc("GET /docs", getDocs)
 
 	// Student handlers
 	mux.HandleFunc("GET /api/students", auth(getAllStudents))
@@ -857,15 +847,24 @@ func main() {
 	mux.HandleFunc("GET /api/teachers/{id}", auth(getTeacher))
 	mux.HandleFunc("PATCH /api/teachers/{id}", auth(updateTeacher))
 	mux.HandleFunc("DELETE /api/teachers/{id}", auth(deleteTeacher))
+	mux.HandleFunc("POST /api/teachers/{id}/classes/{classId}", auth(linkTeacherToClass))
+	mux.HandleFunc("DELETE /api/teachers/{id}/classes/{classId}", auth(unlinkTeacherFromClass))
+	mux.HandleFunc("GET /api/classes/{id}/teachers", auth(getClassTeachers))
 
 	// Remark handlers
 	mux.HandleFunc("GET /api/remarks", auth(getAllRemarks))
 	mux.HandleFunc("POST /api/remarks", auth(createRemark))
 
+	mux.HandleFunc("POST /api/remarks/import", auth(importRemarks))
+
 	mux.HandleFunc("GET /api/remarks/{id}", auth(getRemark))
 	mux.HandleFunc("PATCH /api/remarks/{id}", auth(updateRemark))
 	mux.HandleFunc("DELETE /api/remarks/{id}", auth(deleteRemark))
 
+	// Skill handlers
+	mux.HandleFunc("GET /api/skills", auth(getAllSkills))
+	mux.HandleFunc("PATCH /api/skills/{id}", auth(updateSkill))
+
 	// Observation handlers
 	mux.HandleFunc("GET /api/observations", auth(getAllObservations))
 	mux.HandleFunc("POST /api/observations", auth(createObservation))
@@ -878,8 +877,158 @@ func main() {
 	mux.HandleFunc("GET /api/observations/teacher/{id}", auth(getObservationsByTeacher))
 	mux.HandleFunc("GET /api/observations/teacher/{teacherId}/student/{studentId}", auth(getObservationsByTeacherOnStudent))
 
-	slog.Info("Starting server")
-	http.ListenAndServe(":8080", mux)
+	// v2 handlers: the routes above under /api/v2 with JSON bodies and
+	// envelopes, the v1 ones are deprecated
+	mux.HandleFunc("GET /api/v2/students", auth(getAllStudentsV2))
+	mux.HandleFunc("POST /api/v2/students", auth(createStudentV2))
+	mux.HandleFunc("GET /api/v2/students/{id}", auth(getStudentV2))
+	mux.HandleFunc("PATCH /api/v2/students/{id}", auth(updateStudentV2))
+	mux.HandleFunc("DELETE /api/v2/students/{id}", auth(deleteStudentV2))
+	mux.HandleFunc("GET /api/v2/students/class/{id}", auth(getStudentsByClassV2))
+	mux.HandleFunc("GET /api/v2/students/enrollments/{id}", auth(getStudentEnrollmentsV2))
+
+	mux.HandleFunc("GET /api/v2/classes", auth(getAllClassesV2))
+	mux.HandleFunc("POST /api/v2/classes", auth(createClassV2))
+	mux.HandleFunc("GET /api/v2/classes/{id}", auth(getClassV2))
+	mux.HandleFunc("PATCH /api/v2/classes/{id}", auth(updateClassV2))
+	mux.HandleFunc("GET /api/v2/classes/{id}/teachers", auth(getClassTeachersV2))
+
+	mux.HandleFunc("GET /api/v2/teachers", auth(getAllTeachersV2))
+	mux.HandleFunc("POST /api/v2/teachers", auth(createTeacherV2))
+	mux.HandleFunc("GET /api/v2/teachers/{id}", auth(getTeacherV2))
+	mux.HandleFunc("PATCH /api/v2/teachers/{id}", auth(updateTeacherV2))
+	mux.HandleFunc("DELETE /api/v2/teachers/{id}", auth(deleteTeacherV2))
+	mux.HandleFunc("GET /api/v2/teachers/{id}/classes/{classId}", auth(getTeacherClassV2))
+	mux.HandleFunc("POST /api/v2/teachers/{id}/classes/{classId}", auth(linkTeacherToClassV2))
+	mux.HandleFunc("PATCH /api/v2/teachers/{id}/classes/{classId}", auth(updateTeacherClassV2))
+	mux.HandleFunc("DELETE /api/v2/teachers/{id}/classes/{classId}", auth(unlinkTeacherFromClassV2))
+
+	mux.HandleFunc("GET /api/v2/remarks", auth(getAllRemarksV2))
+	mux.HandleFunc("POST /api/v2/remarks", auth(createRemarkV2))
+	mux.HandleFunc("GET /api/v2/remarks/{id}", auth(getRemarkV2))
+	mux.HandleFunc("PATCH /api/v2/remarks/{id}", auth(updateRemarkV2))
+	mux.HandleFunc("DELETE /api/v2/remarks/{id}", auth(deleteRemarkV2))
+
+	mux.HandleFunc("GET /api/v2/skills", auth(getAllSkillsV2))
+	mux.HandleFunc("PATCH /api/v2/skills/{id}", auth(updateSkillV2))
+
+	mux.HandleFunc("GET /api/v2/framework/nodes", auth(getAllFrameworkNodesV2))
+	mux.HandleFunc("POST /api/v2/framework/nodes", auth(createFrameworkNodeV2))
+	mux.HandleFunc("GET /api/v2/framework/nodes/{id}", auth(getFrameworkNodeV2))
+	mux.HandleFunc("PATCH /api/v2/framework/nodes/{id}", auth(updateFrameworkNodeV2))
+	mux.HandleFunc("DELETE /api/v2/framework/nodes/{id}", auth(deleteFrameworkNodeV2))
+
+	mux.HandleFunc("GET /api/v2/subjects", auth(getAllSubjectsV2))
+	mux.HandleFunc("POST /api/v2/subjects", auth(createSubjectV2))
+	mux.HandleFunc("GET /api/v2/subjects/{id}", auth(getSubjectV2))
+	mux.HandleFunc("PATCH /api/v2/subjects/{id}", auth(updateSubjectV2))
+	mux.HandleFunc("DELETE /api/v2/subjects/{id}", auth(deleteSubjectV2))
+	mux.HandleFunc("GET /api/v2/subjects/assignments", auth(getAllSubjectAssignmentsV2))
+	mux.HandleFunc("POST /api/v2/subjects/assignments", auth(createSubjectAssignmentV2))
+	mux.HandleFunc("GET /api/v2/subjects/assignments/{id}", auth(getSubjectAssignmentV2))
+	mux.HandleFunc("DELETE /api/v2/subjects/assignments/{id}", auth(deleteSubjectAssignmentV2))
+
+	mux.HandleFunc("GET /api/v2/observations", auth(getAllObservationsV2))
+	mux.HandleFunc("POST /api/v2/observations", auth(createObservationV2))
+	mux.HandleFunc("POST /api/v2/observations/batch", auth(createObservationBatch))
+	mux.HandleFunc("GET /api/v2/observations/{id}", auth(getObservationV2))
+	mux.HandleFunc("PATCH /api/v2/observations/{id}", auth(updateObservationV2))
+	mux.HandleFunc("DELETE /api/v2/observations/{id}", auth(deleteObservationV2))
+	mux.HandleFunc("GET /api/v2/observations/student/{id}", auth(getObservationsOnStudentV2))
+	mux.HandleFunc("GET /api/v2/observations/teacher/{id}", auth(getObservationsByTeacherV2))
+	mux.HandleFunc("GET /api/v2/observations/teacher/{teacherId}/student/{studentId}", auth(getObservationsByTeacherOnStudentV2))
+	mux.HandleFunc("GET /api/v2/observations/history/{id}", auth(getObservationHistoryV2))
+	mux.HandleFunc("POST /api/v2/observations/revert/{revisionId}", auth(revertObservationV2))
+
+	mux.HandleFunc("GET /api/v2/evidence/observation/{id}", auth(getObservationEvidenceV2))
+	mux.HandleFunc("POST /api/v2/evidence/observation/{id}", auth(uploadEvidenceV2))
+	mux.HandleFunc("GET /api/v2/evidence/{id}", auth(getEvidenceV2))
+	mux.HandleFunc("GET /api/v2/evidence/content/{id}", auth(downloadEvidenceV2))
+	mux.HandleFunc("GET /api/v2/evidence/thumbnail/{id}", auth(downloadThumbnailV2))
+	mux.HandleFunc("DELETE /api/v2/evidence/{id}", auth(deleteEvidenceV2))
+
+	mux.HandleFunc("GET /api/v2/outcomes", auth(getOutcomesV2))
+	mux.HandleFunc("PUT /api/v2/outcomes", auth(setOutcomesV2))
+
+	mux.HandleFunc("GET /api/v2/reports/students/{id}", auth(getStudentReportV2))
+
+	mux.HandleFunc("GET /api/v2/years", auth(getAllSchoolYearsV2))
+	mux.HandleFunc("POST /api/v2/years", auth(createSchoolYearV2))
+	mux.HandleFunc("GET /api/v2/years/{id}", auth(getSchoolYearV2))
+	mux.HandleFunc("PATCH /api/v2/years/{id}", auth(updateSchoolYearV2))
+	mux.HandleFunc("DELETE /api/v2/years/{id}", auth(deleteSchoolYearV2))
+
+	mux.HandleFunc("GET /api/v2/terms", auth(getAllTermsV2))
+	mux.HandleFunc("POST /api/v2/terms", auth(createTermV2))
+	mux.HandleFunc("GET /api/v2/terms/{id}", auth(getTermV2))
+	mux.HandleFunc("PATCH /api/v2/terms/{id}", auth(updateTermV2))
+	mux.HandleFunc("DELETE /api/v2/terms/{id}", auth(deleteTermV2))
+
+	mux.HandleFunc("GET /api/v2/rollovers", auth(getAllRolloversV2))
+	mux.HandleFunc("POST /api/v2/rollovers", auth(createRolloverV2))
+	mux.HandleFunc("GET /api/v2/rollovers/{id}", auth(getRolloverV2))
+	mux.HandleFunc("POST /api/v2/rollovers/undo/{id}", auth(undoRolloverV2))
+
+	// Admin handlers
+	mux.HandleFunc("GET /api/admin/backup", auth(downloadBackup))
+
+	return mux
+}
+
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if (command == "config" || command == "openapi" || command == "blobs" || command == "users") && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = command+" "+args[0], args[1:]
+	}
+
+	var err error
+	CONFIG, args, err = loadConfig(command, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(slog.New(newLogHandler(CONFIG.LogFormat, CONFIG.slogLevel())))
+
+	DB, err = sql.Open("sqlite3_instrumented", CONFIG.DatabasePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	BLOBS = newLocalBlobStore(CONFIG.BlobDir)
+
+	switch command {
+	case "serve":
+		err = serve()
+	case "backup":
+		err = backupCommand(args)
+	case "restore":
+		err = restoreCommand(args)
+	case "export":
+		err = exportCommand(args)
+	case "import":
+		err = importCommand(args)
+	case "config print":
+		err = printConfig()
+	case "openapi check", "openapi print":
+		err = openapiCommand(command)
+	case "blobs gc", "blobs verify":
+		err = blobsCommand(command)
+	case "users link":
+		err = linkUserCommand(args)
+	default:
+		err = fmt.Errorf("unknown command %q, expected serve, backup, restore, export, import, config print, openapi check|print, blobs gc|verify or users link", command)
+	}
+
+	// Closing waits for queries still running, so nothing is cut mid-write
+	closeErr := DB.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		slog.Error("Command failed", "command", command, "err", err)
+		os.Exit(1)
+	}
 }
 
 // Note: request form only accepts content-type: application/x-www-form-urlencoded
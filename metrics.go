@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Metrics are kept in memory and rendered in the Prometheus text format by
+// /metrics. Label values are joined into a single key per series.
+
+var LATENCY_BUCKETS = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+func newCounterVec(name string, help string, labels ...string) *counterVec {
+	counter := &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+	if len(labels) == 0 {
+		counter.values[""] = 0
+	}
+	return counter
+}
+
+func newHistogramVec(name string, help string, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: LATENCY_BUCKETS, series: map[string]*histogram{}}
+}
+
+func labelKey(names []string, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(values[i])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (c *counterVec) inc(values ...string) {
+	key := labelKey(c.labels, values)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+func (h *histogramVec) observe(seconds float64, values ...string) {
+	key := labelKey(h.labels, values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += seconds
+}
+
+func writeSeries(w io.Writer, name string, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		writeSeries(w, c.name, key, c.values[key])
+	}
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		prefix := key
+		if prefix != "" {
+			prefix += ","
+		}
+		for i, bound := range h.buckets {
+			writeSeries(w, h.name+"_bucket", prefix+"le="+strconv.Quote(strconv.FormatFloat(bound, 'g', -1, 64)), float64(series.counts[i]))
+		}
+		writeSeries(w, h.name+"_bucket", prefix+`le="+Inf"`, float64(series.count))
+		writeSeries(w, h.name+"_sum", key, series.sum)
+		writeSeries(w, h.name+"_count", key, float64(series.count))
+	}
+}
+
+func writeGauge(w io.Writer, name string, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	writeSeries(w, name, "", value)
+}
+
+var (
+	HTTP_REQUESTS = newCounterVec("svalutation_http_requests_total", "HTTP requests by route pattern, method and status code.", "route", "method", "code")
+	HTTP_DURATION = newHistogramVec("svalutation_http_request_duration_seconds", "HTTP request latency by route pattern and method.", "route", "method")
+	DB_QUERIES    = newCounterVec("svalutation_db_queries_total", "Database statements by kind and outcome.", "kind", "outcome")
+	DB_DURATION   = newHistogramVec("svalutation_db_query_duration_seconds", "Database statement latency by kind.", "kind")
+	AUTH_FAILURES = newCounterVec("svalutation_auth_failures_total", "Rejected logins.")
+)
+
+// Remembers the status code a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Counts and times every request by the mux pattern that serves it, so
+// /api/students/{id} is one series however many students there are.
+func instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		HTTP_REQUESTS.inc(route, r.Method, strconv.Itoa(recorder.status))
+		HTTP_DURATION.observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+func activeSessions() int {
+	SESSIONS.Lock()
+	defer SESSIONS.Unlock()
+
+	active, now := 0, time.Now()
+	for _, expiry := range SESSIONS.expiry {
+		if now.Before(expiry) {
+			active++
+		}
+	}
+	return active
+}
+
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	var observationsToday, observationsTotal, students int64
+	err := DB.QueryRow("SELECT COUNT(*) FROM observations WHERE date >= date('now')").Scan(&observationsToday)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	err = DB.QueryRow("SELECT COUNT(*) FROM observations").Scan(&observationsTotal)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	err = DB.QueryRow("SELECT COUNT(*) FROM students").Scan(&students)
+	if errorCheck(&w, err, 500) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	HTTP_REQUESTS.write(w)
+	HTTP_DURATION.write(w)
+	DB_QUERIES.write(w)
+	DB_DURATION.write(w)
+	AUTH_FAILURES.write(w)
+	writeGauge(w, "svalutation_active_sessions", "Logins currently remembered by the session cache.", float64(activeSessions()))
+	writeGauge(w, "svalutation_observations_today", "Observations dated today (UTC).", float64(observationsToday))
+	writeGauge(w, "svalutation_observations", "Observations recorded in total.", float64(observationsTotal))
+	writeGauge(w, "svalutation_students", "Students in the database.", float64(students))
+	return
+}
+
+// The sqlite3 driver with every statement counted and timed. main opens the
+// database through it; one-off connections like snapshot checks don't need it.
+type instrumentedDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+type instrumentedConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (d *instrumentedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func observeQuery(kind string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	DB_QUERIES.inc(kind, outcome)
+	DB_DURATION.observe(time.Since(start).Seconds(), kind)
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	observeQuery("exec", start, err)
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	observeQuery("query", start, err)
+	return rows, err
+}
+
+func init() {
+	sql.Register("sqlite3_instrumented", &instrumentedDriver{})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// Schema changes applied on top of the original schema.sql, in order.
+// PRAGMA user_version records how many of them a database already has,
+// so SCHEMA_VERSION is always len(migrations).
+var migrations = []string{
+	// 1: skills catalog and natural key for remarks
+	`CREATE TABLE IF NOT EXISTS "skills" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"name" TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY("id" AUTOINCREMENT)
+	);
+	INSERT OR IGNORE INTO skills (id) SELECT DISTINCT skill FROM remarks;
+	ALTER TABLE remarks ADD COLUMN "code" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE remarks ADD COLUMN "archived" INTEGER NOT NULL DEFAULT 0;
+	UPDATE remarks SET code = CAST(id AS TEXT);
+	CREATE UNIQUE INDEX IF NOT EXISTS "remarks_natural_key" ON "remarks" ("skill", "level", "code");`,
+	// 2: outcome scale replacing the achieved flag of observations
+	`CREATE TABLE IF NOT EXISTS "outcomes" (
+		"code" TEXT NOT NULL UNIQUE,
+		"label" TEXT NOT NULL,
+		"rank" INTEGER NOT NULL UNIQUE,
+		"achieved" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY("code")
+	);
+	INSERT INTO outcomes (code, label, rank, achieved) VALUES
+		('not_yet', 'Not yet', 0, 0),
+		('partially', 'Partially', 1, 0),
+		('achieved', 'Achieved', 2, 1),
+		('exceeded', 'Exceeded', 3, 1);
+	ALTER TABLE observations ADD COLUMN "outcome" TEXT NOT NULL DEFAULT 'not_yet';
+	ALTER TABLE observations ADD COLUMN "score" REAL;
+	UPDATE observations SET outcome = 'achieved' WHERE achieved IN (1, 'true');
+	ALTER TABLE observations DROP COLUMN "achieved";`,
+	// 3: date is when the observation was made, recorded_at when it was entered
+	`ALTER TABLE observations ADD COLUMN "recorded_at" DATETIME;
+	UPDATE observations SET recorded_at = date;`,
+	// 4: notes and evidence files on observations
+	`ALTER TABLE observations ADD COLUMN "notes" TEXT NOT NULL DEFAULT '';
+	CREATE TABLE IF NOT EXISTS "evidence" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"observation" INTEGER NOT NULL,
+		"filename" TEXT NOT NULL,
+		"media_type" TEXT NOT NULL,
+		"size" INTEGER NOT NULL,
+		"blob" TEXT NOT NULL,
+		"uploaded_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("observation") REFERENCES "observations"
+	);`,
+	// 5: checksums and thumbnails of evidence, logins tied to a teacher.
+	// Files stored before keyed blobs have no checksum until blobs verify.
+	`ALTER TABLE evidence ADD COLUMN "sha256" TEXT;
+	ALTER TABLE evidence ADD COLUMN "thumbnail" TEXT;
+	ALTER TABLE credentials ADD COLUMN "teacher" INTEGER REFERENCES "teachers";`,
+	// 6: edit history of observations, starting from their current state
+	`CREATE TABLE IF NOT EXISTS "observation_revisions" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"observation" INTEGER NOT NULL,
+		"action" TEXT NOT NULL,
+		"user" TEXT,
+		"changed_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		"reverted_from" INTEGER,
+		"teacher" INTEGER NOT NULL,
+		"student" INTEGER NOT NULL,
+		"remark" INTEGER NOT NULL,
+		"date" DATETIME NOT NULL,
+		"outcome" TEXT NOT NULL,
+		"score" REAL,
+		"notes" TEXT NOT NULL,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("observation") REFERENCES "observations",
+		FOREIGN KEY("reverted_from") REFERENCES "observation_revisions"
+	);
+	INSERT INTO observation_revisions (observation, action, changed_at, teacher, student, remark, date, outcome, score, notes)
+		SELECT id, 'create', COALESCE(recorded_at, date), teacher, student, remark, date, outcome, score, notes FROM observations ORDER BY id;`,
+	// 7: school years and their terms, classes belong to a year
+	`CREATE TABLE IF NOT EXISTS "school_years" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"name" TEXT NOT NULL UNIQUE,
+		"start_date" TEXT NOT NULL,
+		"end_date" TEXT NOT NULL,
+		PRIMARY KEY("id" AUTOINCREMENT)
+	);
+	CREATE TABLE IF NOT EXISTS "terms" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"year" INTEGER NOT NULL,
+		"name" TEXT NOT NULL,
+		"start_date" TEXT NOT NULL,
+		"end_date" TEXT NOT NULL,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		UNIQUE("year", "name"),
+		FOREIGN KEY("year") REFERENCES "school_years"
+	);
+	ALTER TABLE classes ADD COLUMN "year" INTEGER REFERENCES "school_years";`,
+	// 8: school year rollovers, kept so they can be undone, and graduates
+	`ALTER TABLE students ADD COLUMN "graduated" INTEGER REFERENCES "school_years";
+	CREATE TABLE IF NOT EXISTS "rollovers" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"from_year" INTEGER NOT NULL,
+		"to_year" INTEGER NOT NULL,
+		"user" TEXT,
+		"performed_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		"copy_teachers" INTEGER NOT NULL DEFAULT 0,
+		"undone_at" DATETIME,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("from_year") REFERENCES "school_years",
+		FOREIGN KEY("to_year") REFERENCES "school_years"
+	);
+	CREATE TABLE IF NOT EXISTS "rollover_classes" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"rollover" INTEGER NOT NULL,
+		"action" TEXT NOT NULL,
+		"from_class" INTEGER NOT NULL,
+		"to_class" INTEGER,
+		"name" TEXT,
+		"created" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("rollover") REFERENCES "rollovers"
+	);
+	CREATE TABLE IF NOT EXISTS "rollover_students" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"rollover" INTEGER NOT NULL,
+		"student" INTEGER NOT NULL,
+		"action" TEXT NOT NULL,
+		"from_class" INTEGER NOT NULL,
+		"to_class" INTEGER,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("rollover") REFERENCES "rollovers"
+	);
+	CREATE TABLE IF NOT EXISTS "rollover_assignments" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"rollover" INTEGER NOT NULL,
+		"assignment" INTEGER NOT NULL,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("rollover") REFERENCES "rollovers"
+	);`,
+	// 9: which class each student was in when, starting from their current
+	// class. Graduates' enrollments end with the year they graduated in.
+	`CREATE TABLE IF NOT EXISTS "enrollments" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"student" INTEGER NOT NULL,
+		"class" INTEGER NOT NULL,
+		"start_date" TEXT,
+		"end_date" TEXT,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("student") REFERENCES "students",
+		FOREIGN KEY("class") REFERENCES "classes"
+	);
+	INSERT INTO enrollments (student, class, end_date)
+		SELECT id, class, (SELECT end_date FROM school_years WHERE id = graduated) FROM students ORDER BY id;`,
+	// 10: subjects, the subject of each skill and who teaches what where
+	`CREATE TABLE IF NOT EXISTS "subjects" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"name" TEXT NOT NULL UNIQUE,
+		PRIMARY KEY("id" AUTOINCREMENT)
+	);
+	ALTER TABLE skills ADD COLUMN "subject" INTEGER REFERENCES "subjects";
+	CREATE TABLE IF NOT EXISTS "teacher_subjects" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"teacher" INTEGER NOT NULL,
+		"class" INTEGER NOT NULL,
+		"subject" INTEGER NOT NULL,
+		PRIMARY KEY("id" AUTOINCREMENT),
+		UNIQUE("teacher", "class", "subject"),
+		FOREIGN KEY("teacher") REFERENCES "teachers",
+		FOREIGN KEY("class") REFERENCES "classes",
+		FOREIGN KEY("subject") REFERENCES "subjects"
+	);`,
+	// 11: one link per teacher and class, with the role the teacher has in it
+	`DELETE FROM classes_teachers WHERE id NOT IN (SELECT MIN(id) FROM classes_teachers GROUP BY teacher_id, class_id);
+	ALTER TABLE classes_teachers ADD COLUMN "role" TEXT NOT NULL DEFAULT 'lead';
+	CREATE UNIQUE INDEX IF NOT EXISTS "classes_teachers_pair" ON "classes_teachers" ("teacher_id", "class_id");`,
+	// 12: the competency framework, a tree of nodes with skills on its leaves
+	`CREATE TABLE IF NOT EXISTS "framework_nodes" (
+		"id" INTEGER NOT NULL UNIQUE,
+		"parent" INTEGER,
+		"name" TEXT NOT NULL,
+		"code" TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY("id" AUTOINCREMENT),
+		FOREIGN KEY("parent") REFERENCES "framework_nodes"
+	);
+	ALTER TABLE skills ADD COLUMN "node" INTEGER REFERENCES "framework_nodes";`,
+}
+
+var SCHEMA_VERSION = len(migrations)
+
+func migrate(db *sql.DB) error {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version > SCHEMA_VERSION {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SCHEMA_VERSION)
+	}
+
+	for ; version < SCHEMA_VERSION; version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(migrations[version])
+		if err == nil {
+			_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		slog.Info("Applied migration", "version", version+1)
+	}
+	return nil
+}
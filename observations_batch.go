@@ -0,0 +1,118 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const BATCH_MAX_ENTRIES = 500
+
+// Checks every entry of batch dated date and returns the indexes of the usable ones
+// with what's wrong with the others. Errors are only for the database.
+func validateBatchEntries(q querier, batch v2.ObservationBatch, date time.Time) ([]int, []v2.BatchEntryError, error) {
+	var valid []int
+	var failed []v2.BatchEntryError
+	seen := map[int64]int{}
+	for i, entry := range batch.Entries {
+		if first, ok := seen[entry.StudentId]; ok {
+			failed = append(failed, v2.BatchEntryError{Index: i, StudentId: entry.StudentId, Message: fmt.Sprintf("student %d is already in entry %d", entry.StudentId, first)})
+			continue
+		}
+		seen[entry.StudentId] = i
+
+		err := checkExists(q, "student", entry.StudentId)
+		if err == nil {
+			err = validateOutcome(q, entry.Outcome)
+		}
+		if err == nil {
+			err = validateNotes(entry.Notes)
+		}
+		if err == nil {
+			err = checkTeaches(q, batch.TeacherId, entry.StudentId, batch.RemarkId, date)
+		}
+		var requestErr *requestError
+		if errors.As(err, &requestErr) {
+			failed = append(failed, v2.BatchEntryError{Index: i, StudentId: entry.StudentId, Message: requestErr.message})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		valid = append(valid, i)
+	}
+	return valid, failed, nil
+}
+
+// Records one remark for many students in a single transaction. Any bad
+// entry rejects the whole batch, unless ?partial=true asks to record the
+// good ones and report the rest.
+func createObservationBatch(w http.ResponseWriter, r *http.Request) {
+	partial := r.URL.Query().Get("partial") == "true"
+
+	var batch v2.ObservationBatch
+	err := decodeV2(r, &batch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	result := v2.ObservationBatchResult{Ids: []int64{}, Failed: []v2.BatchEntryError{}}
+	err = withTx(func(tx *sql.Tx) error {
+		if len(batch.Entries) == 0 || len(batch.Entries) > BATCH_MAX_ENTRIES {
+			return invalid("entries must have between 1 and %d elements", BATCH_MAX_ENTRIES)
+		}
+		if err := checkExists(tx, "teacher", batch.TeacherId); err != nil {
+			return err
+		}
+		if err := checkExists(tx, "remark", batch.RemarkId); err != nil {
+			return err
+		}
+		now := time.Now()
+		date := now
+		if batch.Date != nil {
+			date = *batch.Date
+		}
+		if err := validateObservationDate(tx, date); err != nil {
+			return err
+		}
+
+		valid, failed, err := validateBatchEntries(tx, batch, date)
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 && (!partial || len(valid) == 0) {
+			return &requestError{
+				status:  http.StatusUnprocessableEntity,
+				message: fmt.Sprintf("%d of %d entries are invalid", len(failed), len(batch.Entries)),
+				entries: failed,
+			}
+		}
+
+		for _, i := range valid {
+			entry := batch.Entries[i]
+			inserted, err := tx.Exec("INSERT INTO observations (teacher, student, remark, outcome, score, date, recorded_at, notes) VALUES(?, ?, ?, ?, ?, ?, ?, ?)", batch.TeacherId, entry.StudentId, batch.RemarkId, entry.Outcome, entry.Score, sqliteTime(date), sqliteTime(now), entry.Notes)
+			if err != nil {
+				return err
+			}
+			id, err := inserted.LastInsertId()
+			if err != nil {
+				return err
+			}
+			if err := recordRevision(tx, requestUser(r), id, "create", nil); err != nil {
+				return err
+			}
+			result.Ids = append(result.Ids, id)
+		}
+		if failed != nil {
+			result.Failed = failed
+		}
+		return nil
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusCreated, v2.Data[v2.ObservationBatchResult]{Data: result})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestObservationBatch(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+	teacher, students, remark := createClassroom(t, c, "Ada", "Bruno")
+
+	batch := client.ObservationBatch{
+		TeacherId: teacher.Id,
+		RemarkId:  remark.Id,
+		Entries: []client.ObservationBatchEntry{
+			{StudentId: students[0].Id, Outcome: "achieved"},
+			{StudentId: 999, Outcome: "achieved"},
+			{StudentId: students[1].Id, Outcome: "not_yet"},
+			{StudentId: students[0].Id, Outcome: "exceeded"},
+		},
+	}
+	countObservations := func() int {
+		t.Helper()
+		observations, err := c.ListObservations(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(observations)
+	}
+
+	// One bad entry rejects the whole batch
+	_, err := c.RecordObservations(ctx, batch, false)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("got %v, want a 422 APIError", err)
+	}
+	if apiErr.Message != "2 of 4 entries are invalid" {
+		t.Errorf("got message %q", apiErr.Message)
+	}
+	if n := countObservations(); n != 0 {
+		t.Errorf("a rejected batch recorded %d observations", n)
+	}
+
+	// A partial batch records the good entries and reports the others
+	result, err := c.RecordObservations(ctx, batch, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Ids) != 2 {
+		t.Errorf("got ids %v, want two", result.Ids)
+	}
+	if len(result.Failed) != 2 || result.Failed[0].Index != 1 || result.Failed[1].Index != 3 {
+		t.Errorf("got failed entries %+v, want entries 1 and 3", result.Failed)
+	}
+	if n := countObservations(); n != 2 {
+		t.Errorf("got %d observations, want 2", n)
+	}
+	for _, id := range result.Ids {
+		history, err := c.ObservationHistory(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || history[0].Action != "create" {
+			t.Errorf("observation %d got history %+v, want one create", id, history)
+		}
+	}
+
+	// Without good entries even a partial batch fails
+	batch.Entries = batch.Entries[1:2]
+	_, err = c.RecordObservations(ctx, batch, true)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got %v, want a 422 APIError", err)
+	}
+	if n := countObservations(); n != 2 {
+		t.Errorf("got %d observations, want 2", n)
+	}
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"api/entities/v2"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed openapi.html
+var DOCS_PAGE []byte
+
+// A query string or form field of an operation. Path parameters are read
+// from the route pattern instead.
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+}
+
+func query(name string, typ string, description string) apiParam {
+	return apiParam{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// Request forms are always application/x-www-form-urlencoded.
+func form(name string, typ string, description string, required bool) apiParam {
+	return apiParam{Name: name, In: "form", Type: typ, Description: description, Required: required}
+}
+
+type apiOperation struct {
+	Summary string
+	// Longer notes, like why a route looks the way it does
+	Description string
+	Tag         string
+	Public      bool
+	Params      []apiParam
+	// Raw request bodies by content type, for endpoints that take files
+	Body []string
+	// A value of the type taken as a JSON request body
+	Request any
+	// A value of the type returned as JSON on success, nil for an empty body
+	Response any
+	// Content type of a non-JSON success body
+	ResponseType string
+	Status       int
+	// Closed to logins tied to another teacher
+	Restricted bool
+}
+
+// ?limit= and ?offset= of v2 lists, which are always paged
+var pagingV2 = []apiParam{
+	query("limit", "integer", "page size, 50 by default and at most 500"),
+	query("offset", "integer", "results to skip"),
+}
+
+// ?term_id= and ?year_id=, one of them at most
+var periodFilters = []apiParam{
+	query("term_id", "integer", "only what falls in this term"),
+	query("year_id", "integer", "only what falls in this school year"),
+}
+
+// Paging and a term or year filter
+var periodPagingV2 = append(slices.Clip(pagingV2), periodFilters...)
+
+var asOf = query("as_of", "string", "YYYY-MM-DD, the students enrolled in the class on that day instead of those in it now")
+
+// Paging and the filters of v2 observation lists
+var observationFiltersV2 = append(slices.Clip(periodPagingV2),
+	query("outcome", "string", "comma separated outcome codes"),
+	query("min_outcome", "string", "outcome code, matches it and every higher ranked one"),
+	query("achieved", "boolean", "only outcomes that count as achieved, or only those that don't"),
+	query("teacher_id", "integer", ""),
+	query("student_id", "integer", ""),
+	query("remark_id", "integer", ""),
+	query("skill_id", "integer", ""),
+	query("node_id", "integer", "observations on the skills under this framework node"),
+)
+
+// Every route registered in newMux must have an entry here; `openapi check`
+// fails otherwise.
+var API_OPERATIONS = map[string]apiOperation{
+	"GET /status":       {Summary: "Always answers 204, kept for old monitors", Tag: "status", Public: true, Status: http.StatusNoContent},
+	"GET /healthz":      {Summary: "Liveness: the process is serving", Tag: "status", Public: true, Params: []apiParam{query("verbose", "boolean", "add check details, needs credentials")}, Response: HealthReport{}},
+	"GET /readyz":       {Summary: "Readiness: database, schema version and disk space", Tag: "status", Public: true, Params: []apiParam{query("verbose", "boolean", "add check details, needs credentials")}, Response: HealthReport{}},
+	"GET /metrics":      {Summary: "Prometheus metrics", Tag: "status", Public: true, ResponseType: "text/plain"},
+	"GET /openapi.json": {Summary: "This document", Tag: "docs", Public: true, Response: map[string]any{}},
+	"GET /docs":         {Summary: "Browsable API documentation", Tag: "docs", Public: true, ResponseType: "text/html"},
+
+	"GET /api/students": {Summary: "List students", Tag: "students", Response: []Student{}, Params: periodFilters},
+	"POST /api/students": {Summary: "Create a student", Tag: "students", Response: int64(0), Params: []apiParam{
+		form("name", "string", "", true),
+		form("surname", "string", "", true),
+		form("class", "integer", "class id", true),
+	}},
+	"GET /api/students/{id}": {Summary: "Get a student", Tag: "students", Response: Student{}},
+	"PATCH /api/students/{id}": {Summary: "Update a student, empty fields are left unchanged", Tag: "students", Params: []apiParam{
+		form("name", "string", "", false),
+		form("surname", "string", "", false),
+		form("class", "integer", "class id", false),
+	}},
+	"DELETE /api/students/{id}":    {Summary: "Delete a student", Tag: "students"},
+	"GET /api/students/class/{id}": {Summary: "List the students of a class", Tag: "students", Response: []Student{}, Params: append(slices.Clip(periodFilters), asOf)},
+
+	"GET /api/teachers": {Summary: "List teachers with their classes", Tag: "teachers", Response: []Teacher{}, Params: periodFilters},
+	"POST /api/teachers": {Summary: "Create a teacher", Tag: "teachers", Response: int64(0), Params: []apiParam{
+		form("name", "string", "", true),
+		form("surname", "string", "", true),
+		form("classes", "string", "JSON array of class ids, e.g. [1,3]", true),
+	}},
+	"GET /api/teachers/{id}": {Summary: "Get a teacher", Tag: "teachers", Response: Teacher{}},
+	"PATCH /api/teachers/{id}": {Summary: "Update a teacher, empty fields are left unchanged", Tag: "teachers", Params: []apiParam{
+		form("name", "string", "", false),
+		form("surname", "string", "", false),
+		form("classes", "string", "JSON array of class ids, replaces the current ones, [] unlinks every class", false),
+	}},
+	"DELETE /api/teachers/{id}": {Summary: "Delete a teacher", Tag: "teachers"},
+	"POST /api/teachers/{id}/classes/{classId}": {Summary: "Link a teacher to a class, once per class", Tag: "teachers", Response: int64(0), Params: []apiParam{
+		form("role", "string", "lead or support, lead by default", false),
+	}},
+	"DELETE /api/teachers/{id}/classes/{classId}": {Summary: "Unlink a teacher from a class, with the subjects they teach there", Tag: "teachers"},
+	"GET /api/classes/{id}/teachers":              {Summary: "List the teachers of a class with their role", Tag: "teachers", Response: []ClassTeacher{}},
+
+	"GET /api/remarks": {Summary: "List remarks", Tag: "remarks", Response: []Remark{}, Params: []apiParam{
+		query("archived", "boolean", "include archived remarks"),
+	}},
+	"POST /api/remarks": {Summary: "Create a remark", Tag: "remarks", Response: int64(0), Params: []apiParam{
+		form("skill", "integer", "skill id, created if missing", true),
+		form("level", "integer", "", true),
+		form("description", "string", "", true),
+		form("code", "string", "unique within skill and level, the remark id when missing", false),
+	}},
+	"POST /api/remarks/import": {Summary: "Create or update remarks from a CSV or JSON rubric", Tag: "remarks", Response: RemarkImportReport{},
+		Body: []string{"text/csv", "application/json", "multipart/form-data"},
+		Params: []apiParam{
+			query("format", "string", "csv or json, guessed from the file name or content type when missing"),
+			query("dry_run", "boolean", "report the changes without saving them"),
+			query("archive", "boolean", "archive remarks missing from the rubric"),
+		}},
+	"GET /api/remarks/{id}": {Summary: "Get a remark", Tag: "remarks", Response: Remark{}},
+	"PATCH /api/remarks/{id}": {Summary: "Update a remark, empty fields are left unchanged", Tag: "remarks", Params: []apiParam{
+		form("skill", "integer", "skill id, created if missing", false),
+		form("level", "integer", "", false),
+		form("description", "string", "", false),
+		form("code", "string", "", false),
+		form("archived", "boolean", "", false),
+	}},
+	"DELETE /api/remarks/{id}": {Summary: "Delete a remark", Tag: "remarks"},
+
+	"GET /api/skills": {Summary: "List skills", Tag: "skills", Response: []Skill{}},
+	"PATCH /api/skills/{id}": {Summary: "Rename a skill", Tag: "skills", Params: []apiParam{
+		form("name", "string", "", false),
+	}},
+
+	"GET /api/observations": {Summary: "List observations", Tag: "observations", Response: []Observation{}, Params: periodFilters},
+	"POST /api/observations": {Summary: "Record an observation", Tag: "observations", Response: int64(0), Params: []apiParam{
+		form("teacher", "integer", "teacher id", true),
+		form("student", "integer", "student id", true),
+		form("remark", "integer", "remark id", true),
+		form("achieved", "boolean", "recorded as the lowest outcome with the same achieved flag", true),
+		form("date", "string", "when the student was observed, RFC 3339, now by default", false),
+	}},
+	"GET /api/observations/{id}": {Summary: "Get an observation", Tag: "observations", Response: Observation{}},
+	"PATCH /api/observations/{id}": {Summary: "Update an observation, empty fields are left unchanged", Tag: "observations", Params: []apiParam{
+		form("teacher", "integer", "teacher id", false),
+		form("student", "integer", "student id", false),
+		form("remark", "integer", "remark id", false),
+		form("achieved", "boolean", "", false),
+		form("date", "string", "when the student was observed, RFC 3339", false),
+	}},
+	"DELETE /api/observations/{id}":                                 {Summary: "Delete an observation", Tag: "observations"},
+	"GET /api/observations/student/{id}":                            {Summary: "List the observations on a student", Tag: "observations", Response: []Observation{}, Params: periodFilters},
+	"GET /api/observations/teacher/{id}":                            {Summary: "List the observations made by a teacher", Tag: "observations", Response: []Observation{}, Params: periodFilters},
+	"GET /api/observations/teacher/{teacherId}/student/{studentId}": {Summary: "List the observations a teacher made on a student", Tag: "observations", Response: []Observation{}, Params: periodFilters},
+
+	"GET /api/v2/students":                           {Summary: "List students, in a term or year by the year of their class", Tag: "students v2", Response: v2.Page[v2.Student]{}, Params: periodPagingV2},
+	"POST /api/v2/students":                          {Summary: "Create a student", Tag: "students v2", Request: v2.StudentInput{}, Response: v2.Data[v2.Student]{}, Status: http.StatusCreated},
+	"GET /api/v2/students/{id}":                      {Summary: "Get a student", Tag: "students v2", Response: v2.Data[v2.Student]{}},
+	"PATCH /api/v2/students/{id}":                    {Summary: "Update a student, missing fields are left unchanged", Tag: "students v2", Request: v2.StudentPatch{}, Response: v2.Data[v2.Student]{}},
+	"DELETE /api/v2/students/{id}":                   {Summary: "Delete a student without observations", Tag: "students v2", Status: http.StatusNoContent},
+	"GET /api/v2/students/class/{id}":                {Summary: "List the students of a class", Tag: "students v2", Response: v2.Page[v2.Student]{}, Params: append(slices.Clip(periodPagingV2), asOf)},
+	"GET /api/v2/students/enrollments/{id}":          {Summary: "List the classes a student was enrolled in and when", Tag: "students v2", Response: v2.Page[v2.Enrollment]{}, Params: pagingV2},
+	"GET /api/v2/classes":                            {Summary: "List classes, in a term or year those of that year", Tag: "classes v2", Response: v2.Page[v2.Class]{}, Params: periodPagingV2},
+	"POST /api/v2/classes":                           {Summary: "Create a class", Tag: "classes v2", Request: v2.ClassInput{}, Response: v2.Data[v2.Class]{}, Status: http.StatusCreated},
+	"GET /api/v2/classes/{id}":                       {Summary: "Get a class", Tag: "classes v2", Response: v2.Data[v2.Class]{}},
+	"PATCH /api/v2/classes/{id}":                     {Summary: "Update a class, missing fields are left unchanged", Tag: "classes v2", Request: v2.ClassPatch{}, Response: v2.Data[v2.Class]{}},
+	"GET /api/v2/teachers":                           {Summary: "List teachers with their classes, in a term or year those teaching classes of it", Tag: "teachers v2", Response: v2.Page[v2.Teacher]{}, Params: periodPagingV2},
+	"POST /api/v2/teachers":                          {Summary: "Create a teacher", Tag: "teachers v2", Request: v2.TeacherInput{}, Response: v2.Data[v2.Teacher]{}, Status: http.StatusCreated},
+	"GET /api/v2/teachers/{id}":                      {Summary: "Get a teacher", Tag: "teachers v2", Response: v2.Data[v2.Teacher]{}},
+	"PATCH /api/v2/teachers/{id}":                    {Summary: "Update a teacher, missing fields are left unchanged", Tag: "teachers v2", Request: v2.TeacherPatch{}, Response: v2.Data[v2.Teacher]{}},
+	"DELETE /api/v2/teachers/{id}":                   {Summary: "Delete a teacher without observations", Tag: "teachers v2", Status: http.StatusNoContent},
+	"GET /api/v2/teachers/{id}/classes/{classId}":    {Summary: "Get the link of a teacher to a class", Tag: "teachers v2", Response: v2.Data[v2.ClassTeacher]{}},
+	"POST /api/v2/teachers/{id}/classes/{classId}":   {Summary: "Link a teacher to a class as lead or support, once per class", Tag: "teachers v2", Request: v2.ClassTeacherInput{}, Response: v2.Data[v2.ClassTeacher]{}, Status: http.StatusCreated},
+	"PATCH /api/v2/teachers/{id}/classes/{classId}":  {Summary: "Change the role of a teacher in a class", Tag: "teachers v2", Request: v2.ClassTeacherInput{}, Response: v2.Data[v2.ClassTeacher]{}},
+	"DELETE /api/v2/teachers/{id}/classes/{classId}": {Summary: "Unlink a teacher from a class, with the subjects they teach there", Tag: "teachers v2", Status: http.StatusNoContent},
+	"GET /api/v2/classes/{id}/teachers":              {Summary: "List the teachers of a class with their role", Tag: "classes v2", Response: v2.Page[v2.ClassTeacher]{}, Params: pagingV2},
+	"GET /api/v2/remarks":                            {Summary: "List remarks", Tag: "remarks v2", Response: v2.Page[v2.Remark]{}, Params: append([]apiParam{query("archived", "boolean", "include archived remarks")}, pagingV2...)},
+	"POST /api/v2/remarks":                           {Summary: "Create a remark, its skill is created if missing", Tag: "remarks v2", Request: v2.RemarkInput{}, Response: v2.Data[v2.Remark]{}, Status: http.StatusCreated},
+	"GET /api/v2/remarks/{id}":                       {Summary: "Get a remark", Tag: "remarks v2", Response: v2.Data[v2.Remark]{}},
+	"PATCH /api/v2/remarks/{id}":                     {Summary: "Update a remark, missing fields are left unchanged", Tag: "remarks v2", Request: v2.RemarkPatch{}, Response: v2.Data[v2.Remark]{}},
+	"DELETE /api/v2/remarks/{id}":                    {Summary: "Delete a remark without observations, archive it otherwise", Tag: "remarks v2", Status: http.StatusNoContent},
+	"GET /api/v2/skills":                             {Summary: "List skills", Tag: "skills v2", Response: v2.Page[v2.Skill]{}, Params: pagingV2},
+	"PATCH /api/v2/skills/{id}":                      {Summary: "Rename a skill or change its subject or framework leaf, 0 clears either", Tag: "skills v2", Request: v2.SkillPatch{}, Response: v2.Data[v2.Skill]{}},
+	"GET /api/v2/observations":                       {Summary: "List observations", Tag: "observations v2", Response: v2.Page[v2.Observation]{}, Params: observationFiltersV2},
+	"POST /api/v2/observations":                      {Summary: "Record an observation, remarks on a skill of a subject need a teacher of that subject in the student's class", Tag: "observations v2", Request: v2.ObservationInput{}, Response: v2.Data[v2.Observation]{}, Status: http.StatusCreated},
+	"POST /api/v2/observations/batch": {Summary: "Record one remark for many students in one transaction", Tag: "observations v2", Request: v2.ObservationBatch{}, Response: v2.Data[v2.ObservationBatchResult]{}, Status: http.StatusCreated,
+		Params: []apiParam{query("partial", "boolean", "record the valid entries and report the others instead of rejecting the batch")}},
+	"GET /api/v2/observations/{id}":                                    {Summary: "Get an observation", Tag: "observations v2", Response: v2.Data[v2.Observation]{}},
+	"PATCH /api/v2/observations/{id}":                                  {Summary: "Update an observation, missing fields are left unchanged", Tag: "observations v2", Request: v2.ObservationPatch{}, Response: v2.Data[v2.Observation]{}},
+	"DELETE /api/v2/observations/{id}":                                 {Summary: "Delete an observation", Tag: "observations v2", Status: http.StatusNoContent},
+	"GET /api/v2/observations/student/{id}":                            {Summary: "List the observations on a student", Tag: "observations v2", Response: v2.Page[v2.Observation]{}, Params: observationFiltersV2},
+	"GET /api/v2/observations/teacher/{id}":                            {Summary: "List the observations made by a teacher", Tag: "observations v2", Response: v2.Page[v2.Observation]{}, Params: observationFiltersV2},
+	"GET /api/v2/observations/teacher/{teacherId}/student/{studentId}": {Summary: "List the observations a teacher made on a student", Tag: "observations v2", Response: v2.Page[v2.Observation]{}, Params: observationFiltersV2},
+	"GET /api/v2/observations/history/{id}":                            {Summary: "List the revisions of an observation, oldest first, deleted observations included", Description: "Not /api/v2/observations/{id}/history: that pattern conflicts with /api/v2/observations/teacher/{id} and /api/v2/observations/student/{id}, since both would match paths like /api/v2/observations/teacher/history and the router refuses to register either. The action goes before the id as in the other observation routes.", Tag: "observations v2", Response: v2.Page[v2.ObservationRevision]{}, Params: pagingV2},
+	"POST /api/v2/observations/revert/{revisionId}":                    {Summary: "Restore an observation to a revision, recording a new one", Tag: "observations v2", Response: v2.Data[v2.Observation]{}},
+
+	"GET /api/v2/evidence/observation/{id}": {Summary: "List the evidence files of an observation", Tag: "evidence v2", Response: v2.Page[v2.Evidence]{}, Params: pagingV2, Restricted: true},
+	"POST /api/v2/evidence/observation/{id}": {Summary: "Attach an evidence file, as a raw body or the file field of a form", Tag: "evidence v2", Response: v2.Data[v2.Evidence]{}, Status: http.StatusCreated, Restricted: true,
+		Body: []string{"application/octet-stream", "multipart/form-data"},
+		Params: []apiParam{
+			query("filename", "string", "name of a raw body upload"),
+			query("sha256", "string", "hex SHA-256 of the file, a mismatch rejects the upload"),
+		},
+	},
+	"GET /api/v2/evidence/{id}":           {Summary: "Get the metadata of an evidence file", Tag: "evidence v2", Response: v2.Data[v2.Evidence]{}, Restricted: true},
+	"GET /api/v2/evidence/content/{id}":   {Summary: "Download an evidence file, ranges are supported", Tag: "evidence v2", ResponseType: "application/octet-stream", Restricted: true},
+	"GET /api/v2/evidence/thumbnail/{id}": {Summary: "Download the JPEG thumbnail of an image evidence file", Tag: "evidence v2", ResponseType: "image/jpeg", Restricted: true},
+	"DELETE /api/v2/evidence/{id}":        {Summary: "Delete an evidence file, staff logins only", Tag: "evidence v2", Status: http.StatusNoContent},
+
+	"GET /api/v2/outcomes": {Summary: "Get the outcome scale, lowest rank first", Tag: "outcomes v2", Response: v2.Data[[]v2.Outcome]{}},
+	"PUT /api/v2/outcomes": {Summary: "Replace the outcome scale, outcomes in use must stay", Tag: "outcomes v2", Request: []v2.Outcome{}, Response: v2.Data[[]v2.Outcome]{}},
+
+	"GET /api/v2/reports/students/{id}": {Summary: "Outcomes of a student per skill and per framework node", Tag: "reports v2", Response: v2.Data[v2.StudentReport]{}, Params: append(slices.Clip(periodFilters),
+		query("node_id", "integer", "only the skills under this framework node, summed up at every level below it"),
+	)},
+
+	"GET /api/v2/years":         {Summary: "List school years", Tag: "school years v2", Response: v2.Page[v2.SchoolYear]{}, Params: pagingV2},
+	"POST /api/v2/years":        {Summary: "Create a school year, years can't overlap", Tag: "school years v2", Request: v2.SchoolYearInput{}, Response: v2.Data[v2.SchoolYear]{}, Status: http.StatusCreated},
+	"GET /api/v2/years/{id}":    {Summary: "Get a school year", Tag: "school years v2", Response: v2.Data[v2.SchoolYear]{}},
+	"PATCH /api/v2/years/{id}":  {Summary: "Update a school year, missing fields are left unchanged", Tag: "school years v2", Request: v2.SchoolYearPatch{}, Response: v2.Data[v2.SchoolYear]{}},
+	"DELETE /api/v2/years/{id}": {Summary: "Delete a school year without terms or classes", Tag: "school years v2", Status: http.StatusNoContent},
+	"GET /api/v2/terms":         {Summary: "List terms", Tag: "school years v2", Response: v2.Page[v2.Term]{}, Params: append(slices.Clip(pagingV2), query("year_id", "integer", "only the terms of this school year"))},
+	"POST /api/v2/terms":        {Summary: "Create a term within its school year, terms can't overlap", Tag: "school years v2", Request: v2.TermInput{}, Response: v2.Data[v2.Term]{}, Status: http.StatusCreated},
+	"GET /api/v2/terms/{id}":    {Summary: "Get a term", Tag: "school years v2", Response: v2.Data[v2.Term]{}},
+	"PATCH /api/v2/terms/{id}":  {Summary: "Update a term, missing fields are left unchanged", Tag: "school years v2", Request: v2.TermPatch{}, Response: v2.Data[v2.Term]{}},
+	"DELETE /api/v2/terms/{id}": {Summary: "Delete a term", Tag: "school years v2", Status: http.StatusNoContent},
+
+	"GET /api/v2/rollovers":            {Summary: "List school year rollovers", Tag: "school years v2", Response: v2.Page[v2.Rollover]{}, Params: pagingV2},
+	"POST /api/v2/rollovers":           {Summary: "Roll a school year over into the next, promoting its students; a dry run answers 200 and changes nothing", Tag: "school years v2", Request: v2.RolloverInput{}, Response: v2.Data[v2.Rollover]{}, Status: http.StatusCreated},
+	"GET /api/v2/rollovers/{id}":       {Summary: "Get a school year rollover", Tag: "school years v2", Response: v2.Data[v2.Rollover]{}},
+	"POST /api/v2/rollovers/undo/{id}": {Summary: "Undo a rollover within the undo window, if nothing it moved has changed since", Tag: "school years v2", Response: v2.Data[v2.Rollover]{}},
+
+	"GET /api/v2/subjects":         {Summary: "List subjects", Tag: "subjects v2", Response: v2.Page[v2.Subject]{}, Params: pagingV2},
+	"POST /api/v2/subjects":        {Summary: "Create a subject, names are unique", Tag: "subjects v2", Request: v2.SubjectInput{}, Response: v2.Data[v2.Subject]{}, Status: http.StatusCreated},
+	"GET /api/v2/subjects/{id}":    {Summary: "Get a subject", Tag: "subjects v2", Response: v2.Data[v2.Subject]{}},
+	"PATCH /api/v2/subjects/{id}":  {Summary: "Rename a subject", Tag: "subjects v2", Request: v2.SubjectPatch{}, Response: v2.Data[v2.Subject]{}},
+	"DELETE /api/v2/subjects/{id}": {Summary: "Delete a subject no skill or teacher uses", Tag: "subjects v2", Status: http.StatusNoContent},
+	"GET /api/v2/subjects/assignments": {Summary: "List who teaches which subject in which class", Tag: "subjects v2", Response: v2.Page[v2.SubjectAssignment]{}, Params: append(slices.Clip(pagingV2),
+		query("teacher_id", "integer", "only the subjects of this teacher"),
+		query("class_id", "integer", "only the subjects taught in this class"),
+		query("subject_id", "integer", "only the teachers of this subject"),
+	)},
+	"POST /api/v2/subjects/assignments":        {Summary: "Assign a teacher a subject in a class, linking them to the class if needed", Tag: "subjects v2", Request: v2.SubjectAssignmentInput{}, Response: v2.Data[v2.SubjectAssignment]{}, Status: http.StatusCreated},
+	"GET /api/v2/subjects/assignments/{id}":    {Summary: "Get a subject assignment", Tag: "subjects v2", Response: v2.Data[v2.SubjectAssignment]{}},
+	"DELETE /api/v2/subjects/assignments/{id}": {Summary: "Take a subject away from a teacher in a class, the teacher stays linked to the class", Tag: "subjects v2", Status: http.StatusNoContent},
+
+	"GET /api/v2/framework/nodes": {Summary: "List the nodes of the competency framework", Tag: "framework v2", Response: v2.Page[v2.FrameworkNode]{}, Params: append(slices.Clip(pagingV2),
+		query("parent_id", "integer", "only the children of this node, 0 for the roots"),
+	)},
+	"POST /api/v2/framework/nodes":        {Summary: "Create a framework node, under a node without skills", Tag: "framework v2", Request: v2.FrameworkNodeInput{}, Response: v2.Data[v2.FrameworkNode]{}, Status: http.StatusCreated},
+	"GET /api/v2/framework/nodes/{id}":    {Summary: "Get a framework node", Tag: "framework v2", Response: v2.Data[v2.FrameworkNode]{}},
+	"PATCH /api/v2/framework/nodes/{id}":  {Summary: "Rename or move a framework node, missing fields are left unchanged", Tag: "framework v2", Request: v2.FrameworkNodePatch{}, Response: v2.Data[v2.FrameworkNode]{}},
+	"DELETE /api/v2/framework/nodes/{id}": {Summary: "Delete a framework node without children or skills", Tag: "framework v2", Status: http.StatusNoContent},
+
+	"GET /api/admin/backup": {Summary: "Download a consistent snapshot of the database, staff logins only", Tag: "admin", ResponseType: "application/vnd.sqlite3"},
+}
+
+// Builds JSON schemas from Go types, collecting named structs under
+// components/schemas.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			b.components[name] = nil
+			b.components[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// v2 types are prefixed so they don't clash with v1 ones of the same name,
+// and generic envelopes are named after their type argument:
+// v2.Data[v2.Student] becomes v2.StudentData.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if open := strings.Index(name, "["); open >= 0 {
+		argument := name[open+1 : len(name)-1]
+		argument = argument[strings.LastIndexAny(argument, "./")+1:]
+		name = argument + name[:open]
+	}
+	if path.Base(t.PkgPath()) == "v2" {
+		name = "v2." + name
+	}
+	return name
+}
+
+// Mirrors encoding/json: json tags rename or drop fields, omitempty makes
+// them optional.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+var PATH_PARAM = regexp.MustCompile(`\{(\w+)\}`)
+
+func jsonErrorResponse(builder *schemaBuilder, description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": builder.schema(reflect.TypeOf(v2.Error{}))}},
+	}
+}
+
+func errorResponse(description string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+	}
+}
+
+func buildOpenAPI() map[string]any {
+	builder := &schemaBuilder{components: map[string]any{}}
+	paths := map[string]map[string]any{}
+
+	for pattern, op := range API_OPERATIONS {
+		method, path, _ := strings.Cut(pattern, " ")
+		failure := errorResponse
+		if strings.HasPrefix(path, "/api/v2/") {
+			failure = func(description string) map[string]any {
+				return jsonErrorResponse(builder, description)
+			}
+		}
+
+		var parameters []any
+		for _, match := range PATH_PARAM.FindAllStringSubmatch(path, -1) {
+			parameters = append(parameters, map[string]any{
+				"name": match[1], "in": "path", "required": true,
+				"schema": map[string]any{"type": "integer", "format": "int64"},
+			})
+		}
+
+		formProperties := map[string]any{}
+		formRequired := []string{}
+		for _, param := range op.Params {
+			schema := map[string]any{"type": param.Type}
+			if param.In == "form" {
+				if param.Description != "" {
+					schema["description"] = param.Description
+				}
+				formProperties[param.Name] = schema
+				if param.Required {
+					formRequired = append(formRequired, param.Name)
+				}
+				continue
+			}
+			parameters = append(parameters, map[string]any{
+				"name": param.Name, "in": param.In, "required": param.Required,
+				"description": param.Description, "schema": schema,
+			})
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if op.Response != nil {
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": builder.schema(reflect.TypeOf(op.Response))}}
+		} else if op.ResponseType != "" {
+			success["content"] = map[string]any{op.ResponseType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+		responses := map[string]any{
+			fmt.Sprint(status): success,
+			"500":              failure("Internal error"),
+		}
+
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"operationId": strings.ToLower(method) + strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_").Replace(path),
+			"responses":   responses,
+		}
+		if op.Description != "" {
+			operation["description"] = op.Description
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if len(formProperties) > 0 || len(op.Body) > 0 || op.Request != nil {
+			content := map[string]any{}
+			if op.Request != nil {
+				content["application/json"] = map[string]any{"schema": builder.schema(reflect.TypeOf(op.Request))}
+			}
+			if len(formProperties) > 0 {
+				content["application/x-www-form-urlencoded"] = map[string]any{"schema": map[string]any{
+					"type": "object", "properties": formProperties, "required": formRequired,
+				}}
+			}
+			for _, contentType := range op.Body {
+				schema := map[string]any{"type": "string"}
+				if contentType == "multipart/form-data" {
+					schema = map[string]any{"type": "object", "properties": map[string]any{
+						"file": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"},
+					}}
+				}
+				content[contentType] = map[string]any{"schema": schema}
+			}
+			operation["requestBody"] = map[string]any{"required": true, "content": content}
+			responses["400"] = failure("Malformed request")
+		}
+		if op.Request != nil {
+			responses["409"] = failure("Conflicts with existing data")
+			responses["422"] = failure("Invalid field values")
+		}
+		if strings.HasPrefix(path, "/api/v2/") && strings.Contains(path, "{") {
+			responses["404"] = failure("No such resource")
+		}
+		if strings.HasPrefix(path, "/api/v2/") && len(op.Params) > 0 {
+			responses["400"] = failure("Malformed request")
+		}
+		if op.Restricted {
+			responses["403"] = failure("Belongs to another teacher")
+		}
+		if op.Public {
+			operation["security"] = []any{}
+		} else {
+			responses["401"] = failure("Missing or wrong credentials")
+		}
+		if counterpart, ok := v2Counterpart(pattern); ok && API_OPERATIONS[counterpart].Summary != "" {
+			operation["deprecated"] = true
+			operation["description"] = "Deprecated, use " + strings.TrimPrefix(counterpart, method+" ") + " instead."
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Svalutation API",
+			"version":     "2",
+			"description": "v1 routes take application/x-www-form-urlencoded request bodies and are deprecated where a /api/v2 route replaces them. v2 routes take and return JSON with snake_case fields; responses are wrapped in a data envelope, lists are paged and failures come as an error object.",
+		},
+		"security": []any{map[string]any{"basicAuth": []string{}}, map[string]any{"clientCertificate": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": builder.components,
+			"securitySchemes": map[string]any{
+				"basicAuth":         map[string]any{"type": "http", "scheme": "basic"},
+				"clientCertificate": map[string]any{"type": "mutualTLS", "description": "Client certificate signed by the configured CA, when tls_client_auth is enabled"},
+			},
+		},
+	}
+}
+
+var OPENAPI = sync.OnceValue(func() []byte {
+	spec, err := json.Marshal(buildOpenAPI())
+	if err != nil {
+		panic(err)
+	}
+	return spec
+})
+
+func getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(OPENAPI())
+}
+
+func getDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(DOCS_PAGE)
+}
+
+// Routes registered on the mux without an API_OPERATIONS entry, and entries
+// for routes that no longer exist.
+func specMismatches() (undocumented []string, stale []string) {
+	routes := newMux().patterns
+	for _, pattern := range routes {
+		if _, ok := API_OPERATIONS[pattern]; !ok {
+			undocumented = append(undocumented, pattern)
+		}
+	}
+	for pattern := range API_OPERATIONS {
+		if !slices.Contains(routes, pattern) {
+			stale = append(stale, pattern)
+		}
+	}
+	slices.Sort(stale)
+	return undocumented, stale
+}
+
+// openapi check: fail when the spec and the registered routes disagree.
+// openapi print: write the spec to stdout.
+func openapiCommand(subcommand string) error {
+	switch subcommand {
+	case "openapi print":
+		_, err := fmt.Println(string(OPENAPI()))
+		return err
+	case "openapi check":
+		undocumented, stale := specMismatches()
+		for _, pattern := range undocumented {
+			fmt.Println("missing from spec:", pattern)
+		}
+		for _, pattern := range stale {
+			fmt.Println("no such route:", pattern)
+		}
+		if len(undocumented) > 0 || len(stale) > 0 {
+			return fmt.Errorf("spec is out of date with %d routes", len(undocumented)+len(stale))
+		}
+		fmt.Println("spec covers all", len(API_OPERATIONS), "routes")
+		return nil
+	}
+	return fmt.Errorf("usage: openapi check|print")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSpecCoversRoutes(t *testing.T) {
+	undocumented, stale := specMismatches()
+	for _, pattern := range undocumented {
+		t.Errorf("route %s is missing from API_OPERATIONS", pattern)
+	}
+	for _, pattern := range stale {
+		t.Errorf("API_OPERATIONS documents %s, which isn't registered", pattern)
+	}
+}
+
+func TestSpecIsJSON(t *testing.T) {
+	var spec struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(OPENAPI(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI == "" || len(spec.Paths) == 0 {
+		t.Errorf("spec has no openapi version or paths")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Observations are recorded on an outcome scale stored in the outcomes
+// table. v1 only knows achieved or not, which maps to the lowest outcome
+// with the same achieved flag.
+
+var OUTCOME_CODE = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// The outcome v1 writes for achieved.
+func outcomeForAchieved(q querier, achieved bool) (string, error) {
+	var code string
+	err := q.QueryRow("SELECT code FROM outcomes WHERE achieved = ? ORDER BY rank LIMIT 1", achieved).Scan(&code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("the outcome scale has no outcome with achieved = %t", achieved)
+	}
+	return code, err
+}
+
+func validateOutcome(q querier, code string) error {
+	var found string
+	err := q.QueryRow("SELECT code FROM outcomes WHERE code = ?", code).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalid("outcome %q isn't on the outcome scale", code)
+	}
+	return err
+}
+
+// A scale must have at least one achieved and one not achieved outcome so
+// v1 writes can be mapped.
+func validateOutcomeScale(q querier, outcomes []v2.Outcome) error {
+	codes := map[string]bool{}
+	ranks := map[int64]bool{}
+	achieved, notAchieved := false, false
+	for _, outcome := range outcomes {
+		if !OUTCOME_CODE.MatchString(outcome.Code) {
+			return invalid("outcome code %q must be lowercase letters, digits and underscores", outcome.Code)
+		}
+		if codes[outcome.Code] {
+			return invalid("outcome code %q is used twice", outcome.Code)
+		}
+		if ranks[outcome.Rank] {
+			return invalid("outcome rank %d is used twice", outcome.Rank)
+		}
+		if err := requireText("label of "+outcome.Code, outcome.Label); err != nil {
+			return err
+		}
+		codes[outcome.Code], ranks[outcome.Rank] = true, true
+		achieved = achieved || outcome.Achieved
+		notAchieved = notAchieved || !outcome.Achieved
+	}
+	if !achieved || !notAchieved {
+		return invalid("the outcome scale needs at least one achieved and one not achieved outcome")
+	}
+
+	used, err := queryRows(q, func(row scanner, code *string) error {
+		return row.Scan(code)
+	}, "SELECT DISTINCT outcome FROM observations ORDER BY outcome")
+	if err != nil {
+		return err
+	}
+	for _, code := range used {
+		if !codes[code] {
+			return conflict("outcome %q is used by observations, keep it on the scale", code)
+		}
+	}
+	return nil
+}
+
+func getOutcomesV2(w http.ResponseWriter, r *http.Request) {
+	outcomes, err := loadOutcomes(DB)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	data := make([]v2.Outcome, len(outcomes))
+	for i, outcome := range outcomes {
+		data[i] = v2.FromOutcome(outcome)
+	}
+	writeV2(w, http.StatusOK, v2.Data[[]v2.Outcome]{Data: data})
+}
+
+// Replaces the whole outcome scale.
+func setOutcomesV2(w http.ResponseWriter, r *http.Request) {
+	var outcomes []v2.Outcome
+	err := decodeV2(r, &outcomes)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateOutcomeScale(tx, outcomes)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM outcomes")
+		if err != nil {
+			return err
+		}
+		for _, outcome := range outcomes {
+			_, err = tx.Exec("INSERT INTO outcomes (code, label, rank, achieved) VALUES(?, ?, ?, ?)", outcome.Code, outcome.Label, outcome.Rank, outcome.Achieved)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	getOutcomesV2(w, r)
+}
+
+// Adds the filters of the query string to the conditions of an observation
+// list: outcome (comma separated codes), min_outcome, achieved and the ids
+// of the teacher, student, remark, skill or framework node.
+func observationFilters(q querier, r *http.Request, conditions []string, args []any) (string, []any, error) {
+	query := r.URL.Query()
+
+	if codes := query.Get("outcome"); codes != "" {
+		var placeholders []string
+		for _, code := range strings.Split(codes, ",") {
+			if err := validateOutcome(q, code); err != nil {
+				return "", nil, err
+			}
+			placeholders = append(placeholders, "?")
+			args = append(args, code)
+		}
+		conditions = append(conditions, "outcome IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if code := query.Get("min_outcome"); code != "" {
+		if err := validateOutcome(q, code); err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "outcome IN (SELECT code FROM outcomes WHERE rank >= (SELECT rank FROM outcomes WHERE code = ?))")
+		args = append(args, code)
+	}
+	if value := query.Get("achieved"); value != "" {
+		achieved, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("achieved must be true or false")
+		}
+		conditions = append(conditions, "outcome IN (SELECT code FROM outcomes WHERE achieved = ?)")
+		args = append(args, achieved)
+	}
+
+	for _, filter := range []struct{ param, condition string }{
+		{"teacher_id", "teacher = ?"},
+		{"student_id", "student = ?"},
+		{"remark_id", "remark = ?"},
+		{"skill_id", "remark IN (SELECT id FROM remarks WHERE skill = ?)"},
+		{"node_id", UNDER_NODE},
+	} {
+		value := query.Get(filter.param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s must be an integer", filter.param)
+		}
+		conditions = append(conditions, filter.condition)
+		args = append(args, id)
+	}
+
+	conditions, args, err := periodFilter(q, r, "observations", conditions, args)
+	if err != nil {
+		return "", nil, err
+	}
+	return selectWhere("observations", conditions), args, nil
+}
+
+// Lists observations matching conditions and the filters of the request.
+func listObservationsV2(w http.ResponseWriter, r *http.Request, conditions []string, args ...any) {
+	query, args, err := observationFilters(DB, r, conditions, args)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	listV2(w, r, queryObservations, v2.FromObservation, query, args...)
+}
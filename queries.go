@@ -0,0 +1,509 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Loaders shared by the v1 and v2 handlers. They take a querier so they
+// work on DB as well as inside a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans follow the column order of SELECT *, migrations append new columns
+// at the end.
+func scanStudent(row scanner, student *Student) error {
+	return row.Scan(&student.Id, &student.Name, &student.Surname, &student.Class.Id, &student.Graduated)
+}
+
+func scanTeacher(row scanner, teacher *Teacher) error {
+	return row.Scan(&teacher.Id, &teacher.Name, &teacher.Surname)
+}
+
+func scanRemark(row scanner, remark *Remark) error {
+	return row.Scan(&remark.Id, &remark.Skill, &remark.Level, &remark.Description, &remark.Code, &remark.Archived)
+}
+
+func scanObservation(row scanner, observation *Observation) error {
+	return row.Scan(&observation.Id, &observation.Teacher.Id, &observation.Student.Id, &observation.Remark.Id, &observation.Date, &observation.Outcome, &observation.Score, &observation.RecordedAt, &observation.Notes)
+}
+
+func scanOutcome(row scanner, outcome *Outcome) error {
+	return row.Scan(&outcome.Code, &outcome.Label, &outcome.Rank, &outcome.Achieved)
+}
+
+func scanEvidence(row scanner, evidence *Evidence) error {
+	return row.Scan(&evidence.Id, &evidence.Observation, &evidence.Filename, &evidence.MediaType, &evidence.Size, &evidence.Blob, &evidence.UploadedAt, &evidence.Sha256, &evidence.Thumbnail)
+}
+
+func scanSkill(row scanner, skill *Skill) error {
+	return row.Scan(&skill.Id, &skill.Name, &skill.Subject, &skill.Node)
+}
+
+func scanFrameworkNode(row scanner, node *FrameworkNode) error {
+	return row.Scan(&node.Id, &node.Parent, &node.Name, &node.Code)
+}
+
+func scanSubject(row scanner, subject *Subject) error {
+	return row.Scan(&subject.Id, &subject.Name)
+}
+
+func scanClassTeacher(row scanner, link *ClassTeacher) error {
+	return row.Scan(&link.Id, &link.Teacher.Id, &link.Class, &link.Role)
+}
+
+func scanSubjectAssignment(row scanner, assignment *SubjectAssignment) error {
+	return row.Scan(&assignment.Id, &assignment.Teacher, &assignment.Class, &assignment.Subject)
+}
+
+func scanClass(row scanner, class *Class) error {
+	return row.Scan(&class.Id, &class.Name, &class.Year)
+}
+
+func scanSchoolYear(row scanner, year *SchoolYear) error {
+	return row.Scan(&year.Id, &year.Name, &year.Start, &year.End)
+}
+
+func scanTerm(row scanner, term *Term) error {
+	return row.Scan(&term.Id, &term.Year, &term.Name, &term.Start, &term.End)
+}
+
+func scanRevision(row scanner, revision *ObservationRevision) error {
+	return row.Scan(&revision.Id, &revision.Observation, &revision.Action, &revision.User, &revision.ChangedAt, &revision.RevertedFrom, &revision.Teacher, &revision.Student, &revision.Remark, &revision.Date, &revision.Outcome, &revision.Score, &revision.Notes)
+}
+
+func scanEnrollment(row scanner, enrollment *Enrollment) error {
+	return row.Scan(&enrollment.Id, &enrollment.Student, &enrollment.Class.Id, &enrollment.Start, &enrollment.End)
+}
+
+func scanRollover(row scanner, rollover *Rollover) error {
+	return row.Scan(&rollover.Id, &rollover.From, &rollover.To, &rollover.User, &rollover.PerformedAt, &rollover.CopyTeachers, &rollover.UndoneAt)
+}
+
+func loadClass(q querier, id any) (Class, error) {
+	var class Class
+	err := scanClass(q.QueryRow("SELECT * FROM classes WHERE id = ?", id), &class)
+	return class, err
+}
+
+func loadSkill(q querier, id any) (Skill, error) {
+	var skill Skill
+	err := scanSkill(q.QueryRow("SELECT * FROM skills WHERE id = ?", id), &skill)
+	return skill, err
+}
+
+func loadClassTeacher(q querier, teacherId any, classId any) (ClassTeacher, error) {
+	var link ClassTeacher
+	err := scanClassTeacher(q.QueryRow("SELECT * FROM classes_teachers WHERE teacher_id = ? AND class_id = ?", teacherId, classId), &link)
+	if err != nil {
+		return link, err
+	}
+	link.Teacher, err = loadTeacher(q, link.Teacher.Id)
+	return link, err
+}
+
+func loadFrameworkNode(q querier, id any) (FrameworkNode, error) {
+	var node FrameworkNode
+	err := scanFrameworkNode(q.QueryRow("SELECT * FROM framework_nodes WHERE id = ?", id), &node)
+	return node, err
+}
+
+func loadSubject(q querier, id any) (Subject, error) {
+	var subject Subject
+	err := scanSubject(q.QueryRow("SELECT * FROM subjects WHERE id = ?", id), &subject)
+	return subject, err
+}
+
+func loadSubjectAssignment(q querier, id any) (SubjectAssignment, error) {
+	var assignment SubjectAssignment
+	err := scanSubjectAssignment(q.QueryRow("SELECT * FROM teacher_subjects WHERE id = ?", id), &assignment)
+	return assignment, err
+}
+
+func loadSchoolYear(q querier, id any) (SchoolYear, error) {
+	var year SchoolYear
+	err := scanSchoolYear(q.QueryRow("SELECT * FROM school_years WHERE id = ?", id), &year)
+	return year, err
+}
+
+func loadTerm(q querier, id any) (Term, error) {
+	var term Term
+	err := scanTerm(q.QueryRow("SELECT * FROM terms WHERE id = ?", id), &term)
+	return term, err
+}
+
+// Returns every term, earliest first.
+func loadTerms(q querier) ([]Term, error) {
+	return queryTerms(q, "SELECT * FROM terms ORDER BY start_date")
+}
+
+// Returns the term containing the day of date in the school's time zone.
+func termOf(terms []Term, date time.Time) *Term {
+	day := dayOf(date)
+	for i, term := range terms {
+		if term.Start <= day && day <= term.End {
+			return &terms[i]
+		}
+	}
+	return nil
+}
+
+func loadStudent(q querier, id any) (Student, error) {
+	var student Student
+	err := scanStudent(q.QueryRow("SELECT * FROM students WHERE id = ?", id), &student)
+	if err != nil {
+		return student, err
+	}
+	student.Class, err = loadClass(q, student.Class.Id)
+	return student, err
+}
+
+func loadTeacherClasses(q querier, teacherId int64) ([]Class, error) {
+	rows, err := q.Query("SELECT class_id FROM classes_teachers WHERE teacher_id = ? ORDER BY id", teacherId)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var classes []Class
+	for _, id := range ids {
+		class, err := loadClass(q, id)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func loadTeacher(q querier, id any) (Teacher, error) {
+	var teacher Teacher
+	err := scanTeacher(q.QueryRow("SELECT * FROM teachers WHERE id = ?", id), &teacher)
+	if err != nil {
+		return teacher, err
+	}
+	teacher.Classes, err = loadTeacherClasses(q, teacher.Id)
+	return teacher, err
+}
+
+func loadRemark(q querier, id any) (Remark, error) {
+	var remark Remark
+	err := scanRemark(q.QueryRow("SELECT * FROM remarks WHERE id = ?", id), &remark)
+	return remark, err
+}
+
+func loadObservation(q querier, id any) (Observation, error) {
+	var observation Observation
+	err := scanObservation(q.QueryRow("SELECT * FROM observations WHERE id = ?", id), &observation)
+	if err != nil {
+		return observation, err
+	}
+	observations, err := resolveObservations(q, []Observation{observation})
+	if err != nil {
+		return observation, err
+	}
+	return observations[0], nil
+}
+
+func loadEvidence(q querier, id any) (Evidence, error) {
+	var evidence Evidence
+	err := scanEvidence(q.QueryRow("SELECT * FROM evidence WHERE id = ?", id), &evidence)
+	return evidence, err
+}
+
+// Returns the outcome scale, lowest rank first.
+func loadOutcomes(q querier) ([]Outcome, error) {
+	return queryRows(q, scanOutcome, "SELECT * FROM outcomes ORDER BY rank")
+}
+
+// Runs query, a SELECT * on table rows, and scans every row with scan. The
+// rows are closed before returning so callers can run follow-up queries.
+func queryRows[T any](q querier, scan func(scanner, *T) error, query string, args ...any) ([]T, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []T
+	for rows.Next() {
+		var result T
+		if err := scan(rows, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func queryStudents(q querier, query string, args ...any) ([]Student, error) {
+	students, err := queryRows(q, scanStudent, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	classes := map[int64]Class{}
+	for i := range students {
+		class, ok := classes[students[i].Class.Id]
+		if !ok {
+			class, err = loadClass(q, students[i].Class.Id)
+			if err != nil {
+				return nil, err
+			}
+			classes[class.Id] = class
+		}
+		students[i].Class = class
+	}
+	return students, nil
+}
+
+func queryTeachers(q querier, query string, args ...any) ([]Teacher, error) {
+	teachers, err := queryRows(q, scanTeacher, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range teachers {
+		teachers[i].Classes, err = loadTeacherClasses(q, teachers[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return teachers, nil
+}
+
+func queryRemarks(q querier, query string, args ...any) ([]Remark, error) {
+	return queryRows(q, scanRemark, query, args...)
+}
+
+func queryEvidence(q querier, query string, args ...any) ([]Evidence, error) {
+	return queryRows(q, scanEvidence, query, args...)
+}
+
+// Also loads the revision before each one, which holds the old values.
+func queryRevisions(q querier, query string, args ...any) ([]ObservationRevision, error) {
+	revisions, err := queryRows(q, scanRevision, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		if i > 0 && revisions[i-1].Observation == revisions[i].Observation && revisions[i-1].Id < revisions[i].Id {
+			revisions[i].Previous = &revisions[i-1]
+			continue
+		}
+		var previous ObservationRevision
+		err := scanRevision(q.QueryRow("SELECT * FROM observation_revisions WHERE observation = ? AND id < ? ORDER BY id DESC LIMIT 1", revisions[i].Observation, revisions[i].Id), &previous)
+		if err == nil {
+			revisions[i].Previous = &previous
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+func loadRollover(q querier, id any) (Rollover, error) {
+	var rollover Rollover
+	err := scanRollover(q.QueryRow("SELECT * FROM rollovers WHERE id = ?", id), &rollover)
+	if err != nil {
+		return rollover, err
+	}
+	err = loadRolloverChanges(q, &rollover)
+	return rollover, err
+}
+
+func queryRollovers(q querier, query string, args ...any) ([]Rollover, error) {
+	rollovers, err := queryRows(q, scanRollover, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rollovers {
+		if err := loadRolloverChanges(q, &rollovers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rollovers, nil
+}
+
+// Fills in what a rollover did. Classes it went to are built from what it
+// recorded, undoing it deletes the ones it created.
+func loadRolloverChanges(q querier, rollover *Rollover) error {
+	rollover.UndoUntil = rollover.PerformedAt.Add(CONFIG.RolloverUndo.Duration)
+	var err error
+	rollover.Classes, err = queryRows(q, func(row scanner, class *RolloverClass) error {
+		var to *int64
+		var name *string
+		err := row.Scan(&class.Action, &class.From.Id, &to, &name, &class.Created)
+		if to != nil && name != nil {
+			class.To = &Class{Id: *to, Name: *name, Year: &rollover.To}
+		}
+		return err
+	}, "SELECT action, from_class, to_class, name, created FROM rollover_classes WHERE rollover = ? ORDER BY id", rollover.Id)
+	if err != nil {
+		return err
+	}
+	for i := range rollover.Classes {
+		rollover.Classes[i].From, err = loadClass(q, rollover.Classes[i].From.Id)
+		if err != nil {
+			return err
+		}
+	}
+	rollover.Students, err = queryRows(q, func(row scanner, student *RolloverStudent) error {
+		return row.Scan(&student.Student, &student.Action, &student.From, &student.To)
+	}, "SELECT student, action, from_class, to_class FROM rollover_students WHERE rollover = ? ORDER BY id", rollover.Id)
+	if err != nil {
+		return err
+	}
+	rollover.Assignments, err = queryRows(q, func(row scanner, id *int64) error {
+		return row.Scan(id)
+	}, "SELECT assignment FROM rollover_assignments WHERE rollover = ? ORDER BY id", rollover.Id)
+	return err
+}
+
+func queryEnrollments(q querier, query string, args ...any) ([]Enrollment, error) {
+	enrollments, err := queryRows(q, scanEnrollment, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	classes := map[int64]Class{}
+	for i := range enrollments {
+		class, ok := classes[enrollments[i].Class.Id]
+		if !ok {
+			class, err = loadClass(q, enrollments[i].Class.Id)
+			if err != nil {
+				return nil, err
+			}
+			classes[class.Id] = class
+		}
+		enrollments[i].Class = class
+	}
+	return enrollments, nil
+}
+
+func queryClasses(q querier, query string, args ...any) ([]Class, error) {
+	return queryRows(q, scanClass, query, args...)
+}
+
+func querySchoolYears(q querier, query string, args ...any) ([]SchoolYear, error) {
+	return queryRows(q, scanSchoolYear, query, args...)
+}
+
+func queryTerms(q querier, query string, args ...any) ([]Term, error) {
+	return queryRows(q, scanTerm, query, args...)
+}
+
+func querySkills(q querier, query string, args ...any) ([]Skill, error) {
+	return queryRows(q, scanSkill, query, args...)
+}
+
+func queryClassTeachers(q querier, query string, args ...any) ([]ClassTeacher, error) {
+	links, err := queryRows(q, scanClassTeacher, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		links[i].Teacher, err = loadTeacher(q, links[i].Teacher.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+func queryFrameworkNodes(q querier, query string, args ...any) ([]FrameworkNode, error) {
+	return queryRows(q, scanFrameworkNode, query, args...)
+}
+
+func querySubjects(q querier, query string, args ...any) ([]Subject, error) {
+	return queryRows(q, scanSubject, query, args...)
+}
+
+func querySubjectAssignments(q querier, query string, args ...any) ([]SubjectAssignment, error) {
+	return queryRows(q, scanSubjectAssignment, query, args...)
+}
+
+func queryObservations(q querier, query string, args ...any) ([]Observation, error) {
+	observations, err := queryRows(q, scanObservation, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return resolveObservations(q, observations)
+}
+
+// Fills in the teacher, student and remark of observations, which only
+// have their ids, loading each of them once. Achieved is derived from the
+// outcome.
+func resolveObservations(q querier, observations []Observation) ([]Observation, error) {
+	teachers := map[int64]Teacher{}
+	students := map[int64]Student{}
+	remarks := map[int64]Remark{}
+	enrollments := map[int64][]Enrollment{}
+
+	outcomes, err := loadOutcomes(q)
+	if err != nil {
+		return nil, err
+	}
+	achieved := map[string]bool{}
+	for _, outcome := range outcomes {
+		achieved[outcome.Code] = outcome.Achieved
+	}
+	terms, err := loadTerms(q)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range observations {
+		observation := &observations[i]
+		observation.Achieved = achieved[observation.Outcome]
+		if term := termOf(terms, observation.Date); term != nil {
+			observation.Term = &term.Id
+		}
+		teacher, ok := teachers[observation.Teacher.Id]
+		if !ok {
+			teacher, err = loadTeacher(q, observation.Teacher.Id)
+			if err != nil {
+				return nil, err
+			}
+			teachers[teacher.Id] = teacher
+		}
+		student, ok := students[observation.Student.Id]
+		if !ok {
+			student, err = loadStudent(q, observation.Student.Id)
+			if err != nil {
+				return nil, err
+			}
+			students[student.Id] = student
+			enrollments[student.Id], err = queryEnrollments(q, "SELECT * FROM enrollments WHERE student = ?", student.Id)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if enrollment := enrollmentOn(enrollments[student.Id], dayOf(observation.Date)); enrollment != nil {
+			observation.Class = &enrollment.Class
+		}
+		remark, ok := remarks[observation.Remark.Id]
+		if !ok {
+			remark, err = loadRemark(q, observation.Remark.Id)
+			if err != nil {
+				return nil, err
+			}
+			remarks[remark.Id] = remark
+		}
+		observation.Teacher, observation.Student, observation.Remark = teacher, student, remark
+	}
+	return observations, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// One line of a rubric file. Remarks are matched against the database by
+// their natural key (skill + level + code), skills by id.
+type rubricRow struct {
+	Skill       int64  `json:"skill"`
+	SkillName   string `json:"skill_name"`
+	Level       int64  `json:"level"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+type RemarkUpdate struct {
+	Previous Remark
+	Current  Remark
+}
+
+type RemarkImportReport struct {
+	DryRun        bool
+	SkillsCreated []Skill
+	SkillsRenamed []Skill
+	Created       []Remark
+	Updated       []RemarkUpdate
+	Unchanged     int
+	Archived      []Remark
+}
+
+// Reads a rubric from either a raw request body or the "file" field of a
+// multipart form. The format comes from ?format=, the file extension or the
+// content type, in that order.
+func readRubric(r *http.Request) ([]rubricRow, error) {
+	var body io.Reader = r.Body
+	format := r.URL.Query().Get("format")
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(header.Filename), ".")
+		}
+	}
+	if format == "" {
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/json":
+			format = "json"
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "csv":
+		return readRubricCSV(body)
+	case "json":
+		var rows []rubricRow
+		err := json.NewDecoder(body).Decode(&rows)
+		return rows, err
+	}
+	return nil, fmt.Errorf("unsupported rubric format %q, expected csv or json", format)
+}
+
+// CSV rubrics need a header line naming the columns; skill_name is optional.
+func readRubricCSV(body io.Reader) ([]rubricRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"skill", "level", "code", "description"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []rubricRow
+	for line, record := range records[1:] {
+		var row rubricRow
+		row.Skill, err = strconv.ParseInt(field(record, "skill"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid skill: %w", line+2, err)
+		}
+		row.Level, err = strconv.ParseInt(field(record, "level"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid level: %w", line+2, err)
+		}
+		row.SkillName = field(record, "skill_name")
+		row.Code = field(record, "code")
+		row.Description = field(record, "description")
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func validateRubric(rows []rubricRow) error {
+	seen := map[string]bool{}
+	for i, row := range rows {
+		if row.Skill <= 0 || row.Level <= 0 {
+			return fmt.Errorf("entry %d: skill and level must be positive", i+1)
+		}
+		if row.Code == "" || row.Description == "" {
+			return fmt.Errorf("entry %d: code and description are required", i+1)
+		}
+		key := fmt.Sprintf("%d/%d/%s", row.Skill, row.Level, row.Code)
+		if seen[key] {
+			return fmt.Errorf("entry %d: duplicate remark %s", i+1, key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func importRemarks(w http.ResponseWriter, r *http.Request) {
+	rows, err := readRubric(r)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+	err = validateRubric(rows)
+	if errorCheck(&w, err, 400) {
+		return
+	}
+
+	report := RemarkImportReport{DryRun: r.URL.Query().Get("dry_run") == "true"}
+
+	tx, err := DB.Begin()
+	if errorCheck(&w, err, 500) {
+		return
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		var skill Skill
+		err = scanSkill(tx.QueryRow("SELECT * FROM skills WHERE id = ?", row.Skill), &skill)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			errorCheck(&w, err, 500)
+			return
+		}
+		if err != nil {
+			_, err = tx.Exec("INSERT INTO skills (id, name) VALUES(?, ?)", row.Skill, row.SkillName)
+			if errorCheck(&w, err, 500) {
+				return
+			}
+			report.SkillsCreated = append(report.SkillsCreated, Skill{Id: row.Skill, Name: row.SkillName})
+		} else if row.SkillName != "" && row.SkillName != skill.Name {
+			_, err = tx.Exec("UPDATE skills SET name = ? WHERE id = ?", row.SkillName, row.Skill)
+			if errorCheck(&w, err, 500) {
+				return
+			}
+			report.SkillsRenamed = append(report.SkillsRenamed, Skill{Id: row.Skill, Name: row.SkillName})
+		}
+	}
+
+	seen := map[int64]bool{}
+	for _, row := range rows {
+		current := Remark{Skill: row.Skill, Level: row.Level, Code: row.Code, Description: row.Description}
+
+		var previous Remark
+		err = tx.QueryRow("SELECT * FROM remarks WHERE skill = ? AND level = ? AND code = ?", row.Skill, row.Level, row.Code).Scan(&previous.Id, &previous.Skill, &previous.Level, &previous.Description, &previous.Code, &previous.Archived)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			errorCheck(&w, err, 500)
+			return
+		}
+		if err != nil {
+			result, err := tx.Exec("INSERT INTO remarks (skill, level, description, code) VALUES(?, ?, ?, ?)", row.Skill, row.Level, row.Description, row.Code)
+			if errorCheck(&w, err, 500) {
+				return
+			}
+			current.Id, err = result.LastInsertId()
+			if errorCheck(&w, err, 500) {
+				return
+			}
+			seen[current.Id] = true
+			report.Created = append(report.Created, current)
+			continue
+		}
+
+		current.Id = previous.Id
+		seen[current.Id] = true
+		if previous.Description == current.Description && !previous.Archived {
+			report.Unchanged++
+			continue
+		}
+		_, err = tx.Exec("UPDATE remarks SET description = ?, archived = 0 WHERE id = ?", current.Description, current.Id)
+		if errorCheck(&w, err, 500) {
+			return
+		}
+		report.Updated = append(report.Updated, RemarkUpdate{Previous: previous, Current: current})
+	}
+
+	if r.URL.Query().Get("archive") == "true" {
+		remarks, err := tx.Query("SELECT * FROM remarks WHERE archived = 0")
+		if errorCheck(&w, err, 500) {
+			return
+		}
+		for remarks.Next() {
+			var remark Remark
+			err = remarks.Scan(&remark.Id, &remark.Skill, &remark.Level, &remark.Description, &remark.Code, &remark.Archived)
+			if err != nil {
+				remarks.Close()
+				errorCheck(&w, err, 500)
+				return
+			}
+			if !seen[remark.Id] {
+				remark.Archived = true
+				report.Archived = append(report.Archived, remark)
+			}
+		}
+		err = remarks.Err()
+		remarks.Close()
+		if errorCheck(&w, err, 500) {
+			return
+		}
+
+		for _, remark := range report.Archived {
+			_, err = tx.Exec("UPDATE remarks SET archived = 1 WHERE id = ?", remark.Id)
+			if errorCheck(&w, err, 500) {
+				return
+			}
+		}
+	}
+
+	if !report.DryRun {
+		err = tx.Commit()
+		if errorCheck(&w, err, 500) {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+	return
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Adds observations up on the outcome scale.
+type tally struct {
+	v2.OutcomeSummary
+	achieved int
+	scores   []float64
+}
+
+func newTally() *tally {
+	return &tally{OutcomeSummary: v2.OutcomeSummary{Counts: map[string]int{}}}
+}
+
+// Observations are added oldest first.
+func (t *tally) add(scale map[string]Outcome, outcome string, score *float64, date time.Time) {
+	t.Observations++
+	t.Counts[outcome]++
+	t.Latest, t.LatestDate = outcome, date
+	if t.Best == "" || scale[outcome].Rank > scale[t.Best].Rank {
+		t.Best = outcome
+	}
+	if scale[outcome].Achieved {
+		t.achieved++
+	}
+	if score != nil {
+		t.scores = append(t.scores, *score)
+	}
+}
+
+func (t *tally) summary() v2.OutcomeSummary {
+	summary := t.OutcomeSummary
+	summary.AchievedRatio = float64(t.achieved) / float64(t.Observations)
+	if len(t.scores) > 0 {
+		var sum float64
+		for _, score := range t.scores {
+			sum += score
+		}
+		mean := sum / float64(len(t.scores))
+		summary.MeanScore = &mean
+	}
+	return summary
+}
+
+// Sums up the observations of a student per skill on the outcome scale,
+// and per framework node over the skills under it.
+func getStudentReportV2(w http.ResponseWriter, r *http.Request) {
+	// ?term_id= and ?year_id= narrow the report to a period
+	period, periodArgs, err := periodFilter(DB, r, "observations", nil, nil)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	// ?node_id= to the skills under a framework node
+	var nodeId int64
+	if value := r.URL.Query().Get("node_id"); value != "" {
+		nodeId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errorCheckV2(&w, fmt.Errorf("node_id must be an integer"), 400)
+			return
+		}
+		period, periodArgs = append(period, UNDER_NODE), append(periodArgs, nodeId)
+	}
+
+	var report v2.StudentReport
+	err = withTx(func(tx *sql.Tx) error {
+		student, err := loadStudent(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		if nodeId != 0 {
+			if err := checkExists(tx, "framework node", nodeId); err != nil {
+				return err
+			}
+		}
+		conditions := append([]string{"student = ?"}, period...)
+		args := append([]any{student.Id}, periodArgs...)
+		observed := "SELECT remark FROM observations WHERE " + strings.Join(conditions, " AND ")
+		outcomes, err := loadOutcomes(tx)
+		if err != nil {
+			return err
+		}
+		skills, err := querySkills(tx, "SELECT * FROM skills WHERE id IN (SELECT skill FROM remarks WHERE id IN ("+observed+")) ORDER BY id", args...)
+		if err != nil {
+			return err
+		}
+		nodes, err := queryFrameworkNodes(tx, "SELECT * FROM framework_nodes ORDER BY id")
+		if err != nil {
+			return err
+		}
+
+		report = v2.StudentReport{Student: v2.FromStudent(student), Scale: make([]v2.Outcome, len(outcomes)), Skills: make([]v2.SkillReport, len(skills)), Nodes: []v2.NodeReport{}}
+		scale := map[string]Outcome{}
+		for i, outcome := range outcomes {
+			report.Scale[i] = v2.FromOutcome(outcome)
+			scale[outcome.Code] = outcome
+		}
+		bySkill := map[int64]*tally{}
+		skillNode := map[int64]*int64{}
+		for _, skill := range skills {
+			bySkill[skill.Id] = newTally()
+			skillNode[skill.Id] = skill.Node
+		}
+		parents := map[int64]*int64{}
+		children := map[int64][]FrameworkNode{}
+		var roots []FrameworkNode
+		for _, node := range nodes {
+			parents[node.Id] = node.Parent
+			if node.Parent == nil {
+				roots = append(roots, node)
+			} else {
+				children[*node.Parent] = append(children[*node.Parent], node)
+			}
+		}
+		byNode := map[int64]*tally{}
+
+		rows, err := tx.Query("SELECT r.skill, o.outcome, o.score, o.date FROM remarks r JOIN ("+selectWhere("observations", conditions)+") o ON r.id = o.remark ORDER BY o.date, o.id", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var skillId int64
+			var outcome string
+			var score *float64
+			var date time.Time
+			if err := rows.Scan(&skillId, &outcome, &score, &date); err != nil {
+				return err
+			}
+			// Remarks can name a skill missing from skills, nothing
+			// enforces it; there is no skill to report them under
+			if bySkill[skillId] == nil {
+				continue
+			}
+			bySkill[skillId].add(scale, outcome, score, date)
+			seen := map[int64]bool{}
+			for node := skillNode[skillId]; node != nil && !seen[*node]; node = parents[*node] {
+				seen[*node] = true
+				if byNode[*node] == nil {
+					byNode[*node] = newTally()
+				}
+				byNode[*node].add(scale, outcome, score, date)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, skill := range skills {
+			report.Skills[i] = v2.SkillReport{Skill: v2.FromSkill(skill), OutcomeSummary: bySkill[skill.Id].summary()}
+		}
+		var walk func(level []FrameworkNode)
+		walk = func(level []FrameworkNode) {
+			for _, node := range level {
+				if byNode[node.Id] == nil {
+					continue
+				}
+				report.Nodes = append(report.Nodes, v2.NodeReport{Node: v2.FromFrameworkNode(node), OutcomeSummary: byNode[node.Id].summary()})
+				walk(children[node.Id])
+			}
+		}
+		if nodeId != 0 {
+			node, err := loadFrameworkNode(tx, nodeId)
+			if err != nil {
+				return err
+			}
+			roots = []FrameworkNode{node}
+		}
+		walk(roots)
+		return nil
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.StudentReport]{Data: report})
+}
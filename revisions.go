@@ -0,0 +1,94 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Every change to an observation stores its new state as a revision, so a
+// disputed outcome can be traced and put back. The old values of a change
+// are in the revision before it. Deleting an observation keeps its history,
+// which ends with a delete revision of the state it was deleted in.
+
+const REVISION_COLUMNS = "teacher, student, remark, date, outcome, score, notes"
+
+// Snapshots the current state of an observation, by user ("" for the
+// import command). Writes that changed nothing, like a v1 update without
+// fields, leave no revision, except reverts and deletes which always do.
+func recordRevision(tx *sql.Tx, user string, observationId any, action string, revertedFrom *int64) error {
+	var userValue *string
+	if user != "" {
+		userValue = &user
+	}
+	_, err := tx.Exec(`INSERT INTO observation_revisions (observation, action, user, changed_at, reverted_from, `+REVISION_COLUMNS+`)
+		SELECT id, ?1, ?2, ?3, ?4, `+REVISION_COLUMNS+` FROM observations o WHERE id = ?5 AND (?1 IN ('revert', 'delete') OR NOT EXISTS (
+			SELECT 1 FROM observation_revisions r WHERE r.id = (SELECT MAX(id) FROM observation_revisions WHERE observation = o.id)
+			AND r.teacher = o.teacher AND r.student = o.student AND r.remark = o.remark AND datetime(r.date) = datetime(o.date)
+			AND r.outcome = o.outcome AND r.score IS o.score AND r.notes = o.notes))`,
+		action, userValue, sqliteTime(time.Now()), revertedFrom, observationId)
+	return err
+}
+
+func getObservationHistoryV2(w http.ResponseWriter, r *http.Request) {
+	// Deleted observations are found by their revisions
+	var observationId int64
+	err := DB.QueryRow("SELECT id FROM observations WHERE id = ?1 UNION SELECT observation FROM observation_revisions WHERE observation = ?1", r.PathValue("id")).Scan(&observationId)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	listV2(w, r, queryRevisions, v2.FromObservationRevision, "SELECT * FROM observation_revisions WHERE observation = ?", observationId)
+}
+
+// Puts an observation back in the state of one of its revisions, which
+// records a revision of its own. The old state passes the checks of an
+// update, so a revert can't bring back what an update couldn't set.
+func revertObservationV2(w http.ResponseWriter, r *http.Request) {
+	var observation Observation
+	err := withTx(func(tx *sql.Tx) error {
+		var revision ObservationRevision
+		err := scanRevision(tx.QueryRow("SELECT * FROM observation_revisions WHERE id = ?", r.PathValue("revisionId")), &revision)
+		if err != nil {
+			return err
+		}
+		var current Observation
+		err = scanObservation(tx.QueryRow("SELECT * FROM observations WHERE id = ?", revision.Observation), &current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return conflict("observation %d was deleted", revision.Observation)
+		}
+		if err != nil {
+			return err
+		}
+		// What the revision refers to may have been deleted since
+		err = validateObservation(tx, revision.Teacher, revision.Student, revision.Remark, revision.Outcome)
+		if err != nil {
+			return err
+		}
+		if !revision.Date.Equal(current.Date) {
+			if err := validateObservationDate(tx, revision.Date); err != nil {
+				return err
+			}
+		}
+		if revision.Teacher != current.Teacher.Id || revision.Student != current.Student.Id || revision.Remark != current.Remark.Id || !revision.Date.Equal(current.Date) {
+			if err := checkTeaches(tx, revision.Teacher, revision.Student, revision.Remark, revision.Date); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE observations SET teacher = ?, student = ?, remark = ?, outcome = ?, score = ?, date = ?, notes = ? WHERE id = ?", revision.Teacher, revision.Student, revision.Remark, revision.Outcome, revision.Score, sqliteTime(revision.Date), revision.Notes, revision.Observation)
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, requestUser(r), revision.Observation, "revert", &revision.Id)
+		if err != nil {
+			return err
+		}
+		observation, err = loadObservation(tx, revision.Observation)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Observation]{Data: v2.FromObservation(observation)})
+}
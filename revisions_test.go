@@ -0,0 +1,86 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestObservationHistory(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+	teacher, students, remark := createClassroom(t, c, "Ada")
+
+	observation, err := c.CreateObservation(ctx, client.ObservationInput{TeacherId: teacher.Id, StudentId: students[0].Id, RemarkId: remark.Id, Outcome: "not_yet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcome := "achieved"
+	_, err = c.UpdateObservation(ctx, observation.Id, client.ObservationPatch{Outcome: &outcome})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An update that changes nothing leaves no revision
+	_, err = c.UpdateObservation(ctx, observation.Id, client.ObservationPatch{Outcome: &outcome})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := c.ObservationHistory(ctx, observation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d revisions after an update, want 2", len(history))
+	}
+	change, ok := history[1].Changes["outcome"]
+	if history[1].Action != "update" || !ok || change.Old != "not_yet" || change.New != "achieved" || len(history[1].Changes) != 1 {
+		t.Errorf("got update revision %+v, want outcome not_yet to achieved", history[1])
+	}
+	if history[1].User == nil || *history[1].User != "tester" {
+		t.Errorf("update revision has user %v, want tester", history[1].User)
+	}
+
+	reverted, err := c.RevertObservation(ctx, history[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Outcome != "not_yet" {
+		t.Errorf("revert got outcome %s, want not_yet", reverted.Outcome)
+	}
+
+	if err := c.DeleteObservation(ctx, observation.Id); err != nil {
+		t.Fatal(err)
+	}
+	// The history outlives the observation
+	history, err = c.ObservationHistory(ctx, observation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, revision := range history {
+		actions = append(actions, revision.Action)
+	}
+	if len(history) != 4 || actions[0] != "create" || actions[1] != "update" || actions[2] != "revert" || actions[3] != "delete" {
+		t.Fatalf("got actions %v, want create, update, revert and delete", actions)
+	}
+	if history[2].RevertedFrom == nil || *history[2].RevertedFrom != history[0].Id {
+		t.Errorf("revert revision comes from %v, want %d", history[2].RevertedFrom, history[0].Id)
+	}
+	if len(history[3].Changes) != 0 {
+		t.Errorf("delete revision changed %v", history[3].Changes)
+	}
+
+	// A deleted observation can't be brought back
+	_, err = c.RevertObservation(ctx, history[1].Id)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("got %v, want a 409 APIError", err)
+	}
+	_, err = c.ObservationHistory(ctx, observation.Id+1)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want a 404 APIError for an observation that never existed", err)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Rolling a school year over moves its students into the classes of the
+// next one in a single transaction. Each class is promoted to the class of
+// the next grade, 1A to 2A, or graduates, and retained students repeat the
+// year in the class with the same name. Everything it changes is recorded
+// so that it can be undone within CONFIG.RolloverUndo.
+
+// Returned to withTx to throw away the changes of a dry run.
+var errDryRun = errors.New("dry run")
+
+// The grade of a class is the first number in its name.
+var CLASS_GRADE = regexp.MustCompile(`[0-9]+`)
+
+func classGrade(name string) (int, bool) {
+	grade, err := strconv.Atoi(CLASS_GRADE.FindString(name))
+	return grade, err == nil
+}
+
+// The name of the class a class is promoted to, ok is false when the name
+// has no grade.
+func nextClassName(name string) (string, bool) {
+	span := CLASS_GRADE.FindStringIndex(name)
+	if span == nil {
+		return "", false
+	}
+	grade, err := strconv.Atoi(name[span[0]:span[1]])
+	if err != nil {
+		return "", false
+	}
+	return name[:span[0]] + strconv.Itoa(grade+1) + name[span[1]:], true
+}
+
+func rolloverYear(q querier, field string, id int64) (SchoolYear, error) {
+	year, err := loadSchoolYear(q, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return year, invalid("%s: school year %d doesn't exist", field, id)
+	}
+	return year, err
+}
+
+func rollOver(tx *sql.Tx, input v2.RolloverInput, user string) (Rollover, error) {
+	from, err := rolloverYear(tx, "from_year_id", input.FromYearId)
+	if err != nil {
+		return Rollover{}, err
+	}
+	to, err := rolloverYear(tx, "to_year_id", input.ToYearId)
+	if err != nil {
+		return Rollover{}, err
+	}
+	if to.Start <= from.End {
+		return Rollover{}, invalid("school year %s must start after %s ends", to.Name, from.Name)
+	}
+	if input.FinalGrade < 0 {
+		return Rollover{}, invalid("final_grade can't be negative")
+	}
+	var previous int64
+	err = tx.QueryRow("SELECT id FROM rollovers WHERE from_year = ? AND undone_at IS NULL", from.Id).Scan(&previous)
+	if err == nil {
+		return Rollover{}, conflict("school year %s was already rolled over by rollover %d", from.Name, previous)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Rollover{}, err
+	}
+
+	classes, err := queryClasses(tx, "SELECT * FROM classes WHERE year = ? ORDER BY id", from.Id)
+	if err != nil {
+		return Rollover{}, err
+	}
+	if len(classes) == 0 {
+		return Rollover{}, invalid("school year %s has no classes", from.Name)
+	}
+	inYear := map[int64]bool{}
+	for _, class := range classes {
+		inYear[class.Id] = true
+	}
+	overrides := map[int64]v2.RolloverClassInput{}
+	for _, override := range input.Classes {
+		if !inYear[override.ClassId] {
+			return Rollover{}, invalid("class %d isn't in school year %s", override.ClassId, from.Name)
+		}
+		if _, ok := overrides[override.ClassId]; ok {
+			return Rollover{}, invalid("class %d is listed twice", override.ClassId)
+		}
+		if override.Name != nil {
+			if override.Graduate {
+				return Rollover{}, invalid("class %d can't both graduate and move to %s", override.ClassId, *override.Name)
+			}
+			if err := requireText("name", *override.Name); err != nil {
+				return Rollover{}, err
+			}
+		}
+		overrides[override.ClassId] = override
+	}
+	retained := map[int64]bool{}
+	for _, studentId := range input.RetainedStudentIds {
+		var student Student
+		err := scanStudent(tx.QueryRow("SELECT * FROM students WHERE id = ?", studentId), &student)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Rollover{}, invalid("student %d doesn't exist", studentId)
+		}
+		if err != nil {
+			return Rollover{}, err
+		}
+		if !inYear[student.Class.Id] || student.Graduated != nil {
+			return Rollover{}, invalid("student %d isn't in a class of school year %s", studentId, from.Name)
+		}
+		retained[studentId] = true
+	}
+
+	var userValue *string
+	if user != "" {
+		userValue = &user
+	}
+	result, err := tx.Exec("INSERT INTO rollovers (from_year, to_year, user, performed_at, copy_teachers) VALUES(?, ?, ?, ?, ?)",
+		from.Id, to.Id, userValue, sqliteTime(time.Now()), input.CopyTeachers)
+	if err != nil {
+		return Rollover{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Rollover{}, err
+	}
+
+	// Returns the class called name in the new year, created if missing,
+	// and records that the students of class went there.
+	moveTo := func(action string, class Class, name string) (int64, error) {
+		var classId int64
+		created := false
+		err := tx.QueryRow("SELECT id FROM classes WHERE name = ? AND year = ?", name, to.Id).Scan(&classId)
+		if errors.Is(err, sql.ErrNoRows) {
+			result, err := tx.Exec("INSERT INTO classes (name, year) VALUES(?, ?)", name, to.Id)
+			if err != nil {
+				return 0, err
+			}
+			classId, err = result.LastInsertId()
+			if err != nil {
+				return 0, err
+			}
+			created = true
+		} else if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec("INSERT INTO rollover_classes (rollover, action, from_class, to_class, name, created) VALUES(?, ?, ?, ?, ?, ?)",
+			id, action, class.Id, classId, name, created)
+		return classId, err
+	}
+	// Links the teachers of class from to class to, with their role and the
+	// subjects they teach there. Teachers already in class to keep their
+	// role and get the subjects they're missing.
+	copyTeachers := func(from int64, to int64) error {
+		links, err := queryRows(tx, func(row scanner, link *ClassTeacher) error {
+			return row.Scan(&link.Teacher.Id, &link.Role)
+		}, "SELECT teacher_id, role FROM classes_teachers WHERE class_id = ? AND teacher_id NOT IN (SELECT teacher_id FROM classes_teachers WHERE class_id = ?) ORDER BY id", from, to)
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			teacher := link.Teacher.Id
+			result, err := tx.Exec("INSERT INTO classes_teachers (teacher_id, class_id, role) VALUES(?, ?, ?)", teacher, to, link.Role)
+			if err != nil {
+				return err
+			}
+			assignment, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("INSERT INTO rollover_assignments (rollover, assignment) VALUES(?, ?)", id, assignment)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("INSERT OR IGNORE INTO teacher_subjects (teacher, class, subject) SELECT teacher, ?, subject FROM teacher_subjects WHERE teacher = ? AND class = ? ORDER BY id", to, teacher, from)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The class each class of the old year moves to, 0 when it graduates
+	promoted := map[int64]int64{}
+	for _, class := range classes {
+		override := overrides[class.Id]
+		grade, graded := classGrade(class.Name)
+		if override.Graduate || input.FinalGrade > 0 && graded && grade >= input.FinalGrade {
+			_, err := tx.Exec("INSERT INTO rollover_classes (rollover, action, from_class) VALUES(?, 'graduate', ?)", id, class.Id)
+			if err != nil {
+				return Rollover{}, err
+			}
+			promoted[class.Id] = 0
+			continue
+		}
+		name, ok := nextClassName(class.Name)
+		if override.Name != nil {
+			name, ok = *override.Name, true
+		}
+		if !ok {
+			return Rollover{}, invalid("class %s has no grade in its name, give the name of the class it moves to", class.Name)
+		}
+		promoted[class.Id], err = moveTo("promote", class, name)
+		if err != nil {
+			return Rollover{}, err
+		}
+		if input.CopyTeachers {
+			if err := copyTeachers(class.Id, promoted[class.Id]); err != nil {
+				return Rollover{}, err
+			}
+		}
+	}
+
+	students, err := queryStudents(tx, "SELECT * FROM students WHERE graduated IS NULL AND class IN (SELECT id FROM classes WHERE year = ?) ORDER BY id", from.Id)
+	if err != nil {
+		return Rollover{}, err
+	}
+	// The class with the same name in the new year, for retained students
+	repeated := map[int64]int64{}
+	for _, student := range students {
+		action, classId := "promote", promoted[student.Class.Id]
+		switch {
+		case retained[student.Id]:
+			action = "retain"
+			var ok bool
+			classId, ok = repeated[student.Class.Id]
+			if !ok {
+				classId, err = moveTo("retain", student.Class, student.Class.Name)
+				if err != nil {
+					return Rollover{}, err
+				}
+				// They repeat the year with the teachers they had
+				if input.CopyTeachers {
+					if err := copyTeachers(student.Class.Id, classId); err != nil {
+						return Rollover{}, err
+					}
+				}
+				repeated[student.Class.Id] = classId
+			}
+		case classId == 0:
+			action = "graduate"
+		}
+
+		var toClass *int64
+		if action == "graduate" {
+			err = graduateStudent(tx, student.Id, from)
+		} else {
+			toClass = &classId
+			err = moveStudent(tx, student.Id, classId, to.Start)
+		}
+		if err != nil {
+			return Rollover{}, err
+		}
+		_, err = tx.Exec("INSERT INTO rollover_students (rollover, student, action, from_class, to_class) VALUES(?, ?, ?, ?, ?)",
+			id, student.Id, action, student.Class.Id, toClass)
+		if err != nil {
+			return Rollover{}, err
+		}
+	}
+	return loadRollover(tx, id)
+}
+
+// Puts students back where the rollover found them and removes the
+// classes and assignments it created. Refuses if anything it moved has
+// changed since, so nothing done after it is lost.
+func undoRollover(tx *sql.Tx, id any) (Rollover, error) {
+	rollover, err := loadRollover(tx, id)
+	if err != nil {
+		return rollover, err
+	}
+	switch {
+	case rollover.UndoneAt != nil:
+		return rollover, conflict("rollover %d was already undone", rollover.Id)
+	case CONFIG.RolloverUndo.Duration == 0:
+		return rollover, conflict("undoing rollovers is disabled")
+	case time.Now().After(rollover.UndoUntil):
+		return rollover, conflict("rollover %d could only be undone until %s", rollover.Id, rollover.UndoUntil.Format(time.RFC3339))
+	}
+	to, err := loadSchoolYear(tx, rollover.To)
+	if err != nil {
+		return rollover, err
+	}
+	var later int64
+	err = tx.QueryRow("SELECT id FROM rollovers WHERE from_year = ? AND undone_at IS NULL", rollover.To).Scan(&later)
+	if err == nil {
+		return rollover, conflict("undo rollover %d of the following year first", later)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return rollover, err
+	}
+
+	for _, student := range rollover.Students {
+		var class int64
+		var graduated *int64
+		err := tx.QueryRow("SELECT class, graduated FROM students WHERE id = ?", student.Student).Scan(&class, &graduated)
+		if errors.Is(err, sql.ErrNoRows) {
+			return rollover, conflict("student %d was deleted after the rollover", student.Student)
+		}
+		if err != nil {
+			return rollover, err
+		}
+		unchanged := student.To != nil && class == *student.To && graduated == nil ||
+			student.To == nil && class == student.From && graduated != nil && *graduated == rollover.From
+		if !unchanged {
+			return rollover, conflict("student %d was moved after the rollover", student.Student)
+		}
+		err = restoreStudent(tx, student.Student, student.From, to.Start)
+		if err != nil {
+			return rollover, err
+		}
+	}
+	for _, assignment := range rollover.Assignments {
+		var teacher int64
+		err := tx.QueryRow("SELECT teacher_id FROM classes_teachers WHERE id = ?", assignment).Scan(&teacher)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return rollover, err
+		}
+		if err := deleteRow(tx, "classes_teachers", assignment); err != nil {
+			return rollover, err
+		}
+		if err := pruneTeacherSubjects(tx, teacher); err != nil {
+			return rollover, err
+		}
+	}
+	for _, class := range rollover.Classes {
+		if !class.Created {
+			continue
+		}
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM students WHERE class = ?", class.To.Id).Scan(&count)
+		if err != nil {
+			return rollover, err
+		}
+		if count > 0 {
+			return rollover, conflict("class %s got %d students after the rollover, move them first", class.To.Name, count)
+		}
+		_, err = tx.Exec("DELETE FROM classes_teachers WHERE class_id = ?", class.To.Id)
+		if err != nil {
+			return rollover, err
+		}
+		_, err = tx.Exec("DELETE FROM teacher_subjects WHERE class = ?", class.To.Id)
+		if err != nil {
+			return rollover, err
+		}
+		if err := deleteRow(tx, "classes", class.To.Id); err != nil {
+			return rollover, err
+		}
+	}
+	_, err = tx.Exec("UPDATE rollovers SET undone_at = ? WHERE id = ?", sqliteTime(time.Now()), rollover.Id)
+	if err != nil {
+		return rollover, err
+	}
+	return loadRollover(tx, rollover.Id)
+}
+
+func getAllRolloversV2(w http.ResponseWriter, r *http.Request) {
+	listV2(w, r, queryRollovers, v2.FromRollover, "SELECT * FROM rollovers")
+}
+
+func getRolloverV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadRollover, v2.FromRollover)
+}
+
+func createRolloverV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.RolloverInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var rollover Rollover
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		rollover, err = rollOver(tx, input, requestUser(r))
+		if err == nil && input.DryRun {
+			return errDryRun
+		}
+		return err
+	})
+	if errors.Is(err, errDryRun) {
+		rollover.Id = 0
+		writeV2(w, http.StatusOK, v2.Data[v2.Rollover]{Data: v2.FromRollover(rollover)})
+		return
+	}
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/rollovers/"+strconv.FormatInt(rollover.Id, 10), v2.FromRollover(rollover))
+}
+
+func undoRolloverV2(w http.ResponseWriter, r *http.Request) {
+	var rollover Rollover
+	err := withTx(func(tx *sql.Tx) error {
+		var err error
+		rollover, err = undoRollover(tx, r.PathValue("id"))
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Rollover]{Data: v2.FromRollover(rollover)})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"testing"
+	"time"
+)
+
+// Two consecutive school years around today, students can be enrolled in
+// the first one now and moved into the second one when it starts.
+func createYears(t *testing.T, c *client.Client) (client.SchoolYear, client.SchoolYear) {
+	t.Helper()
+	ctx := context.Background()
+	day := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format(time.DateOnly)
+	}
+	from, err := c.CreateSchoolYear(ctx, client.SchoolYearInput{Name: "this year", StartDate: day(-30), EndDate: day(200)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := c.CreateSchoolYear(ctx, client.SchoolYearInput{Name: "next year", StartDate: day(250), EndDate: day(500)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return from, to
+}
+
+func TestRollover(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+
+	from, to := createYears(t, c)
+	first, err := c.CreateClass(ctx, client.ClassInput{Name: "1A", YearId: &from.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := c.CreateClass(ctx, client.ClassInput{Name: "3A", YearId: &from.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teacher, err := c.CreateTeacher(ctx, client.TeacherInput{Name: "Maria", Surname: "Bianchi", ClassIds: []int64{first.Id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := c.CreateSubject(ctx, client.SubjectInput{Name: "Maths"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.AssignSubject(ctx, client.SubjectAssignmentInput{TeacherId: teacher.Id, ClassId: first.Id, SubjectId: subject.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	students := map[string]client.Student{}
+	for name, class := range map[string]int64{"Ada": first.Id, "Bruno": first.Id, "Carla": last.Id} {
+		students[name], err = c.CreateStudent(ctx, client.StudentInput{Name: name, Surname: "Rossi", ClassId: class})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := client.RolloverInput{
+		FromYearId:         from.Id,
+		ToYearId:           to.Id,
+		FinalGrade:         3,
+		RetainedStudentIds: []int64{students["Bruno"].Id},
+		CopyTeachers:       true,
+	}
+	actions := map[int64]string{students["Ada"].Id: "promote", students["Bruno"].Id: "retain", students["Carla"].Id: "graduate"}
+	checkActions := func(rollover client.Rollover) {
+		t.Helper()
+		if len(rollover.Students) != len(actions) {
+			t.Fatalf("got %d students, want %d", len(rollover.Students), len(actions))
+		}
+		for _, student := range rollover.Students {
+			if student.Action != actions[student.StudentId] {
+				t.Errorf("student %d: got %s, want %s", student.StudentId, student.Action, actions[student.StudentId])
+			}
+		}
+	}
+	classesOf := func(year client.SchoolYear) map[string]int64 {
+		t.Helper()
+		classes, err := c.ListClasses(ctx, client.Period{YearId: year.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byName := map[string]int64{}
+		for _, class := range classes {
+			byName[class.Name] = class.Id
+		}
+		return byName
+	}
+	classOf := func(name string) int64 {
+		t.Helper()
+		student, err := c.GetStudent(ctx, students[name].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return student.Class.Id
+	}
+
+	// A dry run reports the rollover and changes nothing
+	input.DryRun = true
+	rollover, err := c.RollOver(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rollover.Id != nil {
+		t.Errorf("dry run got id %d", *rollover.Id)
+	}
+	checkActions(rollover)
+	if classes := classesOf(to); len(classes) != 0 {
+		t.Errorf("dry run created classes %v", classes)
+	}
+	if classOf("Ada") != first.Id {
+		t.Errorf("dry run moved Ada")
+	}
+
+	input.DryRun = false
+	rollover, err = c.RollOver(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rollover.Id == nil {
+		t.Fatal("rollover has no id")
+	}
+	checkActions(rollover)
+	next := classesOf(to)
+	if len(next) != 2 || next["2A"] == 0 || next["1A"] == 0 {
+		t.Fatalf("got classes %v in the next year, want 1A and 2A", next)
+	}
+	if classOf("Ada") != next["2A"] || classOf("Bruno") != next["1A"] {
+		t.Errorf("Ada and Bruno aren't in 2A and 1A of the next year")
+	}
+	graduate, err := c.GetStudent(ctx, students["Carla"].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graduate.GraduatedYearId == nil || *graduate.GraduatedYearId != from.Id {
+		t.Errorf("Carla didn't graduate in %s", from.Name)
+	}
+	// The teacher follows the class and stays with the retained student
+	if rollover.TeachersCopied != 2 {
+		t.Errorf("got %d teachers copied, want 2", rollover.TeachersCopied)
+	}
+	for _, name := range []string{"1A", "2A"} {
+		assignments, err := c.ListSubjectAssignments(ctx, client.SubjectAssignmentFilter{ClassId: next[name]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(assignments) != 1 || assignments[0].TeacherId != teacher.Id || assignments[0].SubjectId != subject.Id {
+			t.Errorf("%s of the next year got subject assignments %+v", name, assignments)
+		}
+	}
+
+	undone, err := c.UndoRollover(ctx, *rollover.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.UndoneAt == nil {
+		t.Errorf("undone rollover has no undone_at")
+	}
+	if classes := classesOf(to); len(classes) != 0 {
+		t.Errorf("undo left classes %v", classes)
+	}
+	if classOf("Ada") != first.Id || classOf("Bruno") != first.Id {
+		t.Errorf("undo didn't put Ada and Bruno back in 1A")
+	}
+	graduate, err = c.GetStudent(ctx, students["Carla"].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graduate.GraduatedYearId != nil || graduate.Class.Id != last.Id {
+		t.Errorf("undo didn't bring Carla back to 3A")
+	}
+	if _, err := c.UndoRollover(ctx, *rollover.Id); err == nil {
+		t.Errorf("undoing twice succeeded")
+	}
+}
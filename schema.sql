@@ -1,22 +1,82 @@
 CREATE table IF NOT EXISTS "classes" (
   "id" INTEGER NOT NULL UNIQUE,
   "name" TEXT NOT NULL,
-  PRIMARY KEY("id" AUTOINCREMENT)
+  "year" INTEGER,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("year") REFERENCES "school_years"
 );
 
 CREATE table IF NOT EXISTS "classes_teachers" (
   "id" INTEGER NOT NULL UNIQUE,
   "teacher_id" INTEGER NOT NULL,
   "class_id" INTEGER NOT NULL,
+  "role" TEXT NOT NULL DEFAULT 'lead',
   PRIMARY KEY("id" AUTOINCREMENT),
   FOREIGN KEY("teacher_id") REFERENCES "teachers"("id"),
   FOREIGN KEY("class_id") REFERENCES "classes"("id")
 );
 
+CREATE UNIQUE INDEX IF NOT EXISTS "classes_teachers_pair" ON "classes_teachers" ("teacher_id", "class_id");
+
 CREATE TABLE IF NOT EXISTS "credentials" (
   "user" TEXT NOT NULL UNIQUE,
   "password" TEXT NOT NULL,
-  PRIMARY KEY("user")
+  "teacher" INTEGER,
+  PRIMARY KEY("user"),
+  FOREIGN KEY("teacher") REFERENCES "teachers"
+);
+
+CREATE table IF NOT EXISTS "enrollments" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "student" INTEGER NOT NULL,
+  "class" INTEGER NOT NULL,
+  "start_date" TEXT,
+  "end_date" TEXT,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("student") REFERENCES "students",
+  FOREIGN KEY("class") REFERENCES "classes"
+);
+
+CREATE table IF NOT EXISTS "evidence" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "observation" INTEGER NOT NULL,
+  "filename" TEXT NOT NULL,
+  "media_type" TEXT NOT NULL,
+  "size" INTEGER NOT NULL,
+  "blob" TEXT NOT NULL,
+  "uploaded_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "sha256" TEXT,
+  "thumbnail" TEXT,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("observation") REFERENCES "observations"
+);
+
+CREATE table IF NOT EXISTS "framework_nodes" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "parent" INTEGER,
+  "name" TEXT NOT NULL,
+  "code" TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("parent") REFERENCES "framework_nodes"
+);
+
+CREATE table IF NOT EXISTS "observation_revisions" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "observation" INTEGER NOT NULL,
+  "action" TEXT NOT NULL,
+  "user" TEXT,
+  "changed_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "reverted_from" INTEGER,
+  "teacher" INTEGER NOT NULL,
+  "student" INTEGER NOT NULL,
+  "remark" INTEGER NOT NULL,
+  "date" DATETIME NOT NULL,
+  "outcome" TEXT NOT NULL,
+  "score" REAL,
+  "notes" TEXT NOT NULL,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("observation") REFERENCES "observations",
+  FOREIGN KEY("reverted_from") REFERENCES "observation_revisions"
 );
 
 CREATE table IF NOT EXISTS "observations" (
@@ -24,33 +84,149 @@ CREATE table IF NOT EXISTS "observations" (
   "teacher" INTEGER NOT NULL,
   "student" INTEGER NOT NULL,
   "remark" INTEGER NOT NULL,
-  "achieved" INTEGER NOT NULL,
   "date" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "outcome" TEXT NOT NULL DEFAULT 'not_yet',
+  "score" REAL,
+  "recorded_at" DATETIME,
+  "notes" TEXT NOT NULL DEFAULT '',
   PRIMARY KEY("id" AUTOINCREMENT),
   FOREIGN KEY("student") REFERENCES "students",
   FOREIGN KEY("teacher") REFERENCES "teachers",
   FOREIGN KEY("remark") REFERENCES "remarks"
 );
 
+CREATE table IF NOT EXISTS "outcomes" (
+  "code" TEXT NOT NULL UNIQUE,
+  "label" TEXT NOT NULL,
+  "rank" INTEGER NOT NULL UNIQUE,
+  "achieved" INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY("code")
+);
+
+INSERT OR IGNORE INTO "outcomes" ("code", "label", "rank", "achieved") VALUES
+  ('not_yet', 'Not yet', 0, 0),
+  ('partially', 'Partially', 1, 0),
+  ('achieved', 'Achieved', 2, 1),
+  ('exceeded', 'Exceeded', 3, 1);
+
 CREATE table IF NOT EXISTS "remarks" (
   "id" INTEGER NOT NULL UNIQUE,
   "skill" INTEGER NOT NULL,
   "level" INTEGER NOT NULL,
   "description" TEXT NOT NULL,
+  "code" TEXT NOT NULL DEFAULT '',
+  "archived" INTEGER NOT NULL DEFAULT 0,
   PRIMARY KEY("id" AUTOINCREMENT)
 );
 
+CREATE UNIQUE INDEX IF NOT EXISTS "remarks_natural_key" ON "remarks" ("skill", "level", "code");
+
+CREATE table IF NOT EXISTS "rollover_assignments" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "rollover" INTEGER NOT NULL,
+  "assignment" INTEGER NOT NULL,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("rollover") REFERENCES "rollovers"
+);
+
+CREATE table IF NOT EXISTS "rollover_classes" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "rollover" INTEGER NOT NULL,
+  "action" TEXT NOT NULL,
+  "from_class" INTEGER NOT NULL,
+  "to_class" INTEGER,
+  "name" TEXT,
+  "created" INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("rollover") REFERENCES "rollovers"
+);
+
+CREATE table IF NOT EXISTS "rollover_students" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "rollover" INTEGER NOT NULL,
+  "student" INTEGER NOT NULL,
+  "action" TEXT NOT NULL,
+  "from_class" INTEGER NOT NULL,
+  "to_class" INTEGER,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("rollover") REFERENCES "rollovers"
+);
+
+CREATE table IF NOT EXISTS "rollovers" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "from_year" INTEGER NOT NULL,
+  "to_year" INTEGER NOT NULL,
+  "user" TEXT,
+  "performed_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "copy_teachers" INTEGER NOT NULL DEFAULT 0,
+  "undone_at" DATETIME,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("from_year") REFERENCES "school_years",
+  FOREIGN KEY("to_year") REFERENCES "school_years"
+);
+
+CREATE table IF NOT EXISTS "school_years" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "name" TEXT NOT NULL UNIQUE,
+  "start_date" TEXT NOT NULL,
+  "end_date" TEXT NOT NULL,
+  PRIMARY KEY("id" AUTOINCREMENT)
+);
+
+CREATE table IF NOT EXISTS "skills" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "name" TEXT NOT NULL DEFAULT '',
+  "subject" INTEGER,
+  "node" INTEGER,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("subject") REFERENCES "subjects",
+  FOREIGN KEY("node") REFERENCES "framework_nodes"
+);
+
 CREATE table IF NOT EXISTS "students" (
   "id" INTEGER NOT NULL UNIQUE,
   "name" TEXT NOT NULL,
   "surname" TEXT NOT NULL,
   "class" INTEGER NOT NULL,
+  "graduated" INTEGER,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  FOREIGN KEY("graduated") REFERENCES "school_years"
+);
+
+CREATE table IF NOT EXISTS "subjects" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "name" TEXT NOT NULL UNIQUE,
   PRIMARY KEY("id" AUTOINCREMENT)
 );
 
+CREATE table IF NOT EXISTS "teacher_subjects" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "teacher" INTEGER NOT NULL,
+  "class" INTEGER NOT NULL,
+  "subject" INTEGER NOT NULL,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  UNIQUE("teacher", "class", "subject"),
+  FOREIGN KEY("teacher") REFERENCES "teachers",
+  FOREIGN KEY("class") REFERENCES "classes",
+  FOREIGN KEY("subject") REFERENCES "subjects"
+);
+
 CREATE table IF NOT EXISTS "teachers" (
   "id" INTEGER NOT NULL UNIQUE,
   "name" TEXT NOT NULL,
   "surname" TEXT NOT NULL,
   PRIMARY KEY("id" AUTOINCREMENT)
-);
\ No newline at end of file
+);
+
+CREATE table IF NOT EXISTS "terms" (
+  "id" INTEGER NOT NULL UNIQUE,
+  "year" INTEGER NOT NULL,
+  "name" TEXT NOT NULL,
+  "start_date" TEXT NOT NULL,
+  "end_date" TEXT NOT NULL,
+  PRIMARY KEY("id" AUTOINCREMENT),
+  UNIQUE("year", "name"),
+  FOREIGN KEY("year") REFERENCES "school_years"
+);
+
+PRAGMA user_version = 12;
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+)
+
+// A ServeMux that remembers the patterns registered on it, so the OpenAPI
+// spec can be checked against the real routes.
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func newRouter() *router {
+	return &router{ServeMux: http.NewServeMux()}
+}
+
+func (r *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.HandleFunc(pattern, handler)
+}
+
+func (r *router) has(pattern string) bool {
+	return slices.Contains(r.patterns, pattern)
+}
+
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: CONFIG.ReadTimeout.Duration,
+		ReadTimeout:       CONFIG.ReadTimeout.Duration,
+		WriteTimeout:      CONFIG.WriteTimeout.Duration,
+		IdleTimeout:       CONFIG.IdleTimeout.Duration,
+		MaxHeaderBytes:    CONFIG.MaxHeaderBytes,
+	}
+}
+
+// Serves handler until SIGINT or SIGTERM, then stops accepting connections
+// and waits up to CONFIG.ShutdownTimeout for in-flight requests to finish.
+func listen(handler http.Handler) error {
+	handler = http.MaxBytesHandler(handler, CONFIG.MaxBodyBytes)
+
+	server := newServer(CONFIG.ListenAddress, handler)
+	servers := []*http.Server{server}
+	start := []func() error{server.ListenAndServe}
+
+	if CONFIG.TLSCert != "" {
+		tlsConfig, err := tlsConfig()
+		if err != nil {
+			return err
+		}
+		server.Handler = hsts(handler)
+		server.TLSConfig = tlsConfig
+		start[0] = func() error { return server.ListenAndServeTLS("", "") }
+
+		if CONFIG.RedirectListen != "" {
+			redirect := newServer(CONFIG.RedirectListen, http.HandlerFunc(redirectToHTTPS))
+			servers = append(servers, redirect)
+			start = append(start, redirect.ListenAndServe)
+			slog.Info("Redirecting HTTP to HTTPS", "address", CONFIG.RedirectListen)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(start))
+	for _, fn := range start {
+		go func() { errs <- fn() }()
+	}
+	slog.Info("Starting server", "address", CONFIG.ListenAddress, "tls", CONFIG.TLSCert != "")
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("Shutting down", "timeout", CONFIG.ShutdownTimeout.Duration)
+	ctx, cancel := context.WithTimeout(context.Background(), CONFIG.ShutdownTimeout.Duration)
+	defer cancel()
+
+	var shutdownErrs []error
+	for _, s := range servers {
+		shutdownErrs = append(shutdownErrs, s.Shutdown(ctx))
+	}
+	err := errors.Join(shutdownErrs...)
+	if err != nil {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
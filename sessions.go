@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Successful logins are remembered for CONFIG.SessionLifetime, so bcrypt
+// only runs once per session instead of on every request. Sessions are keyed
+// by a hash of the credentials and never hold the password itself. The key
+// includes the stored password hash, so changing the password or deleting
+// the user ends their sessions on the next request.
+var SESSIONS = struct {
+	sync.Mutex
+	expiry map[[32]byte]time.Time
+}{expiry: map[[32]byte]time.Time{}}
+
+func sessionKey(user string, hash string, password string) [32]byte {
+	return sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+}
+
+func sessionValid(user string, hash string, password string) bool {
+	SESSIONS.Lock()
+	defer SESSIONS.Unlock()
+
+	key := sessionKey(user, hash, password)
+	expiry, ok := SESSIONS.expiry[key]
+	if ok && time.Now().After(expiry) {
+		delete(SESSIONS.expiry, key)
+		return false
+	}
+	return ok
+}
+
+func rememberSession(user string, hash string, password string) {
+	if CONFIG.SessionLifetime.Duration <= 0 {
+		return
+	}
+	SESSIONS.Lock()
+	defer SESSIONS.Unlock()
+
+	now := time.Now()
+	for key, expiry := range SESSIONS.expiry {
+		if now.After(expiry) {
+			delete(SESSIONS.expiry, key)
+		}
+	}
+	SESSIONS.expiry[sessionKey(user, hash, password)] = now.Add(CONFIG.SessionLifetime.Duration)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Skills may belong to a subject, and teachers are assigned the subjects
+// they teach in each of their classes. Remarks on a skill of a subject can
+// only be observed by a teacher of that subject in the student's class.
+
+// Teachers can only observe remarks of the subjects they teach in the class
+// the student was in on the day. Skills of no subject are open to anyone.
+func checkTeaches(q querier, teacherId any, studentId any, remarkId any, date time.Time) error {
+	var subject *int64
+	err := q.QueryRow("SELECT skills.subject FROM remarks JOIN skills ON skills.id = remarks.skill WHERE remarks.id = ?", remarkId).Scan(&subject)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && subject == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	day := dayOf(date)
+	class, err := classOn(q, studentId, day)
+	if err != nil || class == nil {
+		return err
+	}
+	var found int64
+	err = q.QueryRow("SELECT id FROM teacher_subjects WHERE teacher = ? AND class = ? AND subject = ?", teacherId, *class, *subject).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		var name string
+		if err := q.QueryRow("SELECT name FROM subjects WHERE id = ?", *subject).Scan(&name); err != nil {
+			return err
+		}
+		return invalid("teacher %v doesn't teach %s in class %d, the class of student %v on %s", teacherId, name, *class, studentId, day)
+	}
+	return err
+}
+
+// Drops the subjects of a teacher in classes they no longer teach, after
+// their classes_teachers links changed.
+func pruneTeacherSubjects(tx *sql.Tx, teacherId any) error {
+	_, err := tx.Exec("DELETE FROM teacher_subjects WHERE teacher = ?1 AND class NOT IN (SELECT class_id FROM classes_teachers WHERE teacher_id = ?1)", teacherId)
+	return err
+}
+
+func validateSubject(q querier, id int64, name string) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	var other int64
+	err := q.QueryRow("SELECT id FROM subjects WHERE id != ? AND name = ?", id, name).Scan(&other)
+	if err == nil {
+		return conflict("subject %d is already named %s", other, name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+func getAllSubjectsV2(w http.ResponseWriter, r *http.Request) {
+	listV2(w, r, querySubjects, v2.FromSubject, "SELECT * FROM subjects")
+}
+
+func getSubjectV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadSubject, v2.FromSubject)
+}
+
+func createSubjectV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.SubjectInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var subject Subject
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateSubject(tx, 0, input.Name)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO subjects (name) VALUES(?)", input.Name)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		subject, err = loadSubject(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/subjects/"+strconv.FormatInt(subject.Id, 10), v2.FromSubject(subject))
+}
+
+func updateSubjectV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.SubjectPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var subject Subject
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		subject, err = loadSubject(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		patchField(&subject.Name, patch.Name)
+		err = validateSubject(tx, subject.Id, subject.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE subjects SET name = ? WHERE id = ?", subject.Name, subject.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Subject]{Data: v2.FromSubject(subject)})
+}
+
+// Subjects still tagging skills or taught by someone can't be deleted.
+func deleteSubjectV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		subject, err := loadSubject(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"skills", "teacher_subjects"} {
+			var count int
+			err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE subject = ?", subject.Id).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return conflict("subject %d is still used by %d %s, change or delete them first", subject.Id, count, table)
+			}
+		}
+		return deleteRow(tx, "subjects", subject.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Assignments
+
+// Narrowed with ?teacher_id=, ?class_id= and ?subject_id=.
+func getAllSubjectAssignmentsV2(w http.ResponseWriter, r *http.Request) {
+	var conditions []string
+	var args []any
+	for _, filter := range []struct{ param, condition string }{
+		{"teacher_id", "teacher = ?"},
+		{"class_id", "class = ?"},
+		{"subject_id", "subject = ?"},
+	} {
+		value := r.URL.Query().Get(filter.param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errorCheckV2(&w, fmt.Errorf("%s must be an integer", filter.param), 400)
+			return
+		}
+		conditions = append(conditions, filter.condition)
+		args = append(args, id)
+	}
+	listV2(w, r, querySubjectAssignments, v2.FromSubjectAssignment, selectWhere("teacher_subjects", conditions), args...)
+}
+
+func getSubjectAssignmentV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadSubjectAssignment, v2.FromSubjectAssignment)
+}
+
+// Teaching a subject in a class links the teacher to the class as well.
+func createSubjectAssignmentV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.SubjectAssignmentInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var assignment SubjectAssignment
+	err = withTx(func(tx *sql.Tx) error {
+		if err := checkExists(tx, "teacher", input.TeacherId); err != nil {
+			return err
+		}
+		if err := checkExists(tx, "class", input.ClassId); err != nil {
+			return err
+		}
+		if err := checkExists(tx, "subject", input.SubjectId); err != nil {
+			return err
+		}
+		var found int64
+		err := tx.QueryRow("SELECT id FROM teacher_subjects WHERE teacher = ? AND class = ? AND subject = ?", input.TeacherId, input.ClassId, input.SubjectId).Scan(&found)
+		if err == nil {
+			return conflict("teacher %d already teaches subject %d in class %d, see assignment %d", input.TeacherId, input.SubjectId, input.ClassId, found)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		err = tx.QueryRow("SELECT id FROM classes_teachers WHERE teacher_id = ? AND class_id = ?", input.TeacherId, input.ClassId).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.Exec("INSERT INTO classes_teachers (teacher_id, class_id) VALUES(?, ?)", input.TeacherId, input.ClassId)
+		}
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO teacher_subjects (teacher, class, subject) VALUES(?, ?, ?)", input.TeacherId, input.ClassId, input.SubjectId)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		assignment, err = loadSubjectAssignment(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/subjects/assignments/"+strconv.FormatInt(assignment.Id, 10), v2.FromSubjectAssignment(assignment))
+}
+
+// The teacher stays linked to the class, only the subject goes.
+func deleteSubjectAssignmentV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		assignment, err := loadSubjectAssignment(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "teacher_subjects", assignment.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Thumbnails let galleries show image evidence without downloading the
+// photos. They are made with the standard library decoders, so WebP images
+// get none, and stored as JPEG blobs.
+
+// Longest side of a thumbnail, in pixels
+const THUMBNAIL_SIZE = 256
+
+// Larger images aren't decoded, a small file can expand into a huge bitmap
+const THUMBNAIL_MAX_PIXELS = 50_000_000
+
+var THUMBNAIL_MEDIA_TYPES = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Stores a thumbnail of the image in blob and returns its key, or "" when
+// the media type or size of the image gets no thumbnail.
+func makeThumbnail(blob string, mediaType string) (string, error) {
+	if !THUMBNAIL_MEDIA_TYPES[mediaType] {
+		return "", nil
+	}
+	content, err := BLOBS.Open(blob)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return "", err
+	}
+	if config.Width*config.Height > THUMBNAIL_MAX_PIXELS {
+		return "", nil
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(content)
+	if err != nil {
+		return "", err
+	}
+
+	var encoded bytes.Buffer
+	err = jpeg.Encode(&encoded, scaleDown(img, THUMBNAIL_SIZE), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return "", err
+	}
+	key, _, err := BLOBS.Put(&encoded)
+	return key, err
+}
+
+// Fits img in a size by size square, averaging a grid of up to 4x4 samples
+// of the source for every pixel. Transparent areas turn white, JPEG has no
+// alpha.
+func scaleDown(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+	if width == 0 || height == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		top, bottom := y*bounds.Dy()/height, (y+1)*bounds.Dy()/height
+		for x := range width {
+			left, right := x*bounds.Dx()/width, (x+1)*bounds.Dx()/width
+			stepX, stepY := max(1, (right-left)/4), max(1, (bottom-top)/4)
+
+			var r, g, b, n uint32
+			for sy := top; sy < max(bottom, top+1); sy += stepY {
+				for sx := left; sx < max(right, left+1); sx += stepX {
+					cr, cg, cb, ca := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r += cr + 0xffff - ca
+					g += cg + 0xffff - ca
+					b += cb + 0xffff - ca
+					n++
+				}
+			}
+			scaled.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), 0xff})
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const CERT_RELOAD_INTERVAL = 30 * time.Second
+
+// Serves the certificate at certPath/keyPath, picking up renewals as soon as
+// either file changes on disk so a restart is never needed.
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certPath string, keyPath string) (*certReloader, error) {
+	reloader := &certReloader{certPath: certPath, keyPath: keyPath}
+	err := reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+	go reloader.watch(CERT_RELOAD_INTERVAL)
+	return reloader, nil
+}
+
+func (c *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.certPath, c.keyPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certReloader) reload() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+// A half-written renewal fails to load and keeps the old certificate until
+// the next tick.
+func (c *certReloader) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		modTime, err := c.lastModified()
+		if err != nil {
+			slog.Error("Couldn't stat TLS certificate", "err", err)
+			continue
+		}
+		c.mu.RLock()
+		changed := modTime.After(c.modTime)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := c.reload(); err != nil {
+			slog.Error("Couldn't reload TLS certificate", "err", err)
+			continue
+		}
+		slog.Info("Reloaded TLS certificate", "file", c.certPath)
+	}
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func tlsConfig() (*tls.Config, error) {
+	reloader, err := newCertReloader(CONFIG.TLSCert, CONFIG.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch CONFIG.TLSClientAuth {
+	case "optional":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return config, nil
+	}
+	pem, err := os.ReadFile(CONFIG.TLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + CONFIG.TLSClientCA)
+	}
+	return config, nil
+}
+
+// The name of the verified client certificate the request came with, if any.
+func clientCertUser(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
+
+func hsts(next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(int(CONFIG.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CONFIG.HSTSMaxAge.Duration > 0 {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sends plain HTTP requests to the same path on the HTTPS listener.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	_, port, _ := net.SplitHostPort(CONFIG.ListenAddress)
+	if port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// users link <user> <teacher id|none>: ties a login to a teacher, which
+// limits it to that teacher's evidence. "none" unties it.
+func linkUserCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: users link <user> <teacher id|none>")
+	}
+	err := migrate(DB)
+	if err != nil {
+		return err
+	}
+
+	var teacher *int64
+	if args[1] != "none" {
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("teacher id %q isn't a number", args[1])
+		}
+		if err := checkExists(DB, "teacher", id); err != nil {
+			return err
+		}
+		teacher = &id
+	}
+	result, err := DB.Exec("UPDATE credentials SET teacher = ? WHERE user = ?", teacher, args[0])
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no such user %q", args[0])
+	}
+	return nil
+}
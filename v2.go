@@ -0,0 +1,999 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// /api/v2 serves the same resources as v1 with snake_case JSON in and out,
+// envelopes around every response and stricter semantics: missing rows are
+// 404s, bad input is rejected before anything is written and lists are
+// always paged. v1 stays frozen and is marked deprecated.
+
+const V2_DEFAULT_LIMIT = 50
+const V2_MAX_LIMIT = 500
+
+// Announced in the Deprecation header of v1 responses.
+var V1_DEPRECATED = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// A problem with the request itself, answered with status and message
+// instead of a 500. Batch requests add what's wrong with each entry.
+type requestError struct {
+	status  int
+	message string
+	entries []v2.BatchEntryError
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func invalid(format string, args ...any) error {
+	return &requestError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...any) error {
+	return &requestError{status: http.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+func forbidden(format string, args ...any) error {
+	return &requestError{status: http.StatusForbidden, message: fmt.Sprintf(format, args...)}
+}
+
+func isV2(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/v2/")
+}
+
+// The /api/v2 counterpart of a v1 route pattern or path.
+func v2Counterpart(route string) (string, bool) {
+	before, after, ok := strings.Cut(route, "/api/")
+	if !ok || strings.HasPrefix(after, "v2/") {
+		return "", false
+	}
+	return before + "/api/v2/" + after, true
+}
+
+func writeV2(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeV2Error(w http.ResponseWriter, status int, message string) {
+	writeV2(w, status, v2.Error{Error: v2.ErrorDetail{
+		Status:    status,
+		Message:   message,
+		RequestId: w.Header().Get(REQUEST_ID_HEADER),
+	}})
+}
+
+// errorCheck for v2 handlers. Missing rows, request errors and constraint
+// violations get their own status whatever code says.
+func errorCheckV2(w *http.ResponseWriter, err error, code int) bool {
+	if err == nil {
+		return false
+	}
+	var requestErr *requestError
+	var sqliteErr sqlite3.Error
+	var entries []v2.BatchEntryError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		code, err = http.StatusNotFound, errors.New("not found")
+	case errors.As(err, &requestErr):
+		code, entries = requestErr.status, requestErr.entries
+	case errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint:
+		code = http.StatusConflict
+	}
+	requestId := (*w).Header().Get(REQUEST_ID_HEADER)
+	slog.Error("Request failed", "request_id", requestId, "status", code, "err", err)
+	writeV2(*w, code, v2.Error{Error: v2.ErrorDetail{Status: code, Message: err.Error(), RequestId: requestId, Entries: entries}})
+	return true
+}
+
+func decodeV2(r *http.Request, input any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(input)
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// Runs fn in a transaction, committed only if fn succeeds.
+func withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func patchField[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// ?limit= and ?offset= of a v2 list, limit defaults to V2_DEFAULT_LIMIT.
+func pageV2(r *http.Request) (v2.PageMeta, error) {
+	meta := v2.PageMeta{Limit: V2_DEFAULT_LIMIT}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > V2_MAX_LIMIT {
+			return meta, fmt.Errorf("limit must be between 1 and %d", V2_MAX_LIMIT)
+		}
+		meta.Limit = n
+	}
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return meta, errors.New("offset must be a non-negative integer")
+		}
+		meta.Offset = n
+	}
+	return meta, nil
+}
+
+// Serves one page of query, a SELECT * with an optional WHERE, loading the
+// rows with load and converting them to their v2 shape.
+func listV2[E any, T any](w http.ResponseWriter, r *http.Request, load func(querier, string, ...any) ([]E, error), convert func(E) T, query string, args ...any) {
+	meta, err := pageV2(r)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	err = DB.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&meta.Total)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	results, err := load(DB, query+" ORDER BY id LIMIT ? OFFSET ?", append(args, meta.Limit, meta.Offset)...)
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+
+	page := v2.Page[T]{Data: make([]T, len(results)), Meta: meta}
+	for i, result := range results {
+		page.Data[i] = convert(result)
+	}
+	writeV2(w, http.StatusOK, page)
+}
+
+func getV2[E any, T any](w http.ResponseWriter, r *http.Request, load func(querier, any) (E, error), convert func(E) T) {
+	result, err := load(DB, r.PathValue("id"))
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[T]{Data: convert(result)})
+}
+
+func writeCreatedV2[T any](w http.ResponseWriter, location string, data T) {
+	w.Header().Set("Location", location)
+	writeV2(w, http.StatusCreated, v2.Data[T]{Data: data})
+}
+
+var REFERENCE_TABLES = map[string]string{
+	"class":          "classes",
+	"teacher":        "teachers",
+	"student":        "students",
+	"remark":         "remarks",
+	"observation":    "observations",
+	"school year":    "school_years",
+	"subject":        "subjects",
+	"framework node": "framework_nodes",
+}
+
+// A reference to a missing row is the client's mistake, not a 404 of the
+// resource being written.
+func checkExists(q querier, noun string, id int64) error {
+	var found int64
+	err := q.QueryRow("SELECT id FROM "+REFERENCE_TABLES[noun]+" WHERE id = ?", id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalid("%s %d doesn't exist", noun, id)
+	}
+	return err
+}
+
+// Observations keep their teacher, student and remark alive.
+func checkUnobserved(q querier, noun string, id int64, hint string) error {
+	var count int64
+	err := q.QueryRow("SELECT COUNT(*) FROM observations WHERE "+noun+" = ?", id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return conflict("%s %d has %d observations, %s", noun, id, count, hint)
+	}
+	return nil
+}
+
+func deleteRow(tx *sql.Tx, table string, id int64) error {
+	_, err := tx.Exec("DELETE FROM "+table+" WHERE id = ?", id)
+	return err
+}
+
+// SELECT * FROM table with conditions joined by AND.
+func selectWhere(table string, conditions []string) string {
+	statement := "SELECT * FROM " + table
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return statement
+}
+
+func requireText(field string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return invalid("%s is required", field)
+	}
+	return nil
+}
+
+// Classes
+
+// id is the class being updated, 0 for a new one. Names repeat from year to
+// year but not within one.
+func validateClass(q querier, id int64, name string, yearId *int64) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if yearId != nil {
+		if err := checkExists(q, "school year", *yearId); err != nil {
+			return err
+		}
+	}
+	var other int64
+	err := q.QueryRow("SELECT id FROM classes WHERE id != ? AND name = ? AND year IS ?", id, name, yearId).Scan(&other)
+	if err == nil {
+		return conflict("class %d is already called %s in that year", other, name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// With ?term_id= or ?year_id=, only the classes of that year.
+func getAllClassesV2(w http.ResponseWriter, r *http.Request) {
+	conditions, args, err := periodFilter(DB, r, "classes", nil, nil)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	listV2(w, r, queryClasses, v2.FromClass, selectWhere("classes", conditions), args...)
+}
+
+func getClassV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadClass, v2.FromClass)
+}
+
+func createClassV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.ClassInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var class Class
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateClass(tx, 0, input.Name, input.YearId)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO classes (name, year) VALUES(?, ?)", input.Name, input.YearId)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		class, err = loadClass(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/classes/"+strconv.FormatInt(class.Id, 10), v2.FromClass(class))
+}
+
+func updateClassV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.ClassPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var class Class
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadClass(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		name, yearId := current.Name, current.Year
+		patchField(&name, patch.Name)
+		if patch.YearId != nil {
+			yearId = patch.YearId
+		}
+		err = validateClass(tx, current.Id, name, yearId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE classes SET name = ?, year = ? WHERE id = ?", name, yearId, current.Id)
+		if err != nil {
+			return err
+		}
+		class, err = loadClass(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Class]{Data: v2.FromClass(class)})
+}
+
+// Students
+
+func validateStudent(q querier, name string, surname string, classId int64) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if err := requireText("surname", surname); err != nil {
+		return err
+	}
+	return checkExists(q, "class", classId)
+}
+
+// Lists students matching conditions in the year of ?term_id= or ?year_id=.
+func listStudentsV2(w http.ResponseWriter, r *http.Request, conditions []string, args ...any) {
+	conditions, args, err := periodFilter(DB, r, "students", conditions, args)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	listV2(w, r, queryStudents, v2.FromStudent, selectWhere("students", conditions), args...)
+}
+
+func getAllStudentsV2(w http.ResponseWriter, r *http.Request) {
+	listStudentsV2(w, r, nil)
+}
+
+func getStudentsByClassV2(w http.ResponseWriter, r *http.Request) {
+	conditions, args, err := asOfFilter(r, r.PathValue("id"), nil, nil)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	listStudentsV2(w, r, conditions, args...)
+}
+
+func getStudentV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadStudent, v2.FromStudent)
+}
+
+func createStudentV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.StudentInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var student Student
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateStudent(tx, input.Name, input.Surname, input.ClassId)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO students (name, surname, class) VALUES(?, ?, ?)", input.Name, input.Surname, input.ClassId)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		err = enrollStudent(tx, id, input.ClassId)
+		if err != nil {
+			return err
+		}
+		student, err = loadStudent(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/students/"+strconv.FormatInt(student.Id, 10), v2.FromStudent(student))
+}
+
+func updateStudentV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.StudentPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var student Student
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadStudent(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		name, surname, classId, since := current.Name, current.Surname, current.Class.Id, today()
+		patchField(&name, patch.Name)
+		patchField(&surname, patch.Surname)
+		patchField(&classId, patch.ClassId)
+		patchField(&since, patch.ClassSince)
+		err = validateStudent(tx, name, surname, classId)
+		if err != nil {
+			return err
+		}
+		if patch.ClassSince != nil {
+			if patch.ClassId == nil {
+				return invalid("class_since needs class_id")
+			}
+			if _, err := parseDay("class_since", since); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE students SET name = ?, surname = ? WHERE id = ?", name, surname, current.Id)
+		if err != nil {
+			return err
+		}
+		if patch.ClassId != nil {
+			err = moveStudent(tx, current.Id, classId, since)
+			if err != nil {
+				return err
+			}
+		}
+		student, err = loadStudent(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Student]{Data: v2.FromStudent(student)})
+}
+
+func deleteStudentV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		student, err := loadStudent(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		err = checkUnobserved(tx, "student", student.Id, "delete them first")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM enrollments WHERE student = ?", student.Id)
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "students", student.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Teachers
+
+func validateTeacher(q querier, name string, surname string, classIds []int64) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if err := requireText("surname", surname); err != nil {
+		return err
+	}
+	for _, classId := range classIds {
+		if err := checkExists(q, "class", classId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// With ?term_id= or ?year_id=, only teachers of classes in that year.
+func getAllTeachersV2(w http.ResponseWriter, r *http.Request) {
+	conditions, args, err := periodFilter(DB, r, "teachers", nil, nil)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+	listV2(w, r, queryTeachers, v2.FromTeacher, selectWhere("teachers", conditions), args...)
+}
+
+func getTeacherV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadTeacher, v2.FromTeacher)
+}
+
+func createTeacherV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.TeacherInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var teacher Teacher
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateTeacher(tx, input.Name, input.Surname, input.ClassIds)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO teachers (name, surname) VALUES(?, ?)", input.Name, input.Surname)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		err = setTeacherClasses(tx, id, input.ClassIds)
+		if err != nil {
+			return err
+		}
+		teacher, err = loadTeacher(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/teachers/"+strconv.FormatInt(teacher.Id, 10), v2.FromTeacher(teacher))
+}
+
+func updateTeacherV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.TeacherPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var teacher Teacher
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadTeacher(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		name, surname := current.Name, current.Surname
+		patchField(&name, patch.Name)
+		patchField(&surname, patch.Surname)
+		var classIds []int64
+		if patch.ClassIds != nil {
+			classIds = *patch.ClassIds
+		}
+		err = validateTeacher(tx, name, surname, classIds)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE teachers SET name = ?, surname = ? WHERE id = ?", name, surname, current.Id)
+		if err != nil {
+			return err
+		}
+		if patch.ClassIds != nil {
+			err = setTeacherClasses(tx, current.Id, classIds)
+			if err != nil {
+				return err
+			}
+		}
+		teacher, err = loadTeacher(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Teacher]{Data: v2.FromTeacher(teacher)})
+}
+
+func deleteTeacherV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		teacher, err := loadTeacher(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		err = checkUnobserved(tx, "teacher", teacher.Id, "delete them first")
+		if err != nil {
+			return err
+		}
+		err = setTeacherClasses(tx, teacher.Id, nil)
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "teachers", teacher.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Remarks
+
+// id is the remark being updated, 0 for a new one.
+func validateRemark(q querier, id int64, skill int64, level int64, description string, code string) error {
+	if skill < 1 {
+		return invalid("skill_id must be a positive integer")
+	}
+	if level < 1 {
+		return invalid("level must be a positive integer")
+	}
+	if err := requireText("description", description); err != nil {
+		return err
+	}
+	if err := requireText("code", code); err != nil {
+		return err
+	}
+	var existing int64
+	err := q.QueryRow("SELECT id FROM remarks WHERE skill = ? AND level = ? AND code = ? AND id != ?", skill, level, code, id).Scan(&existing)
+	if err == nil {
+		return conflict("remark %d already has skill %d, level %d and code %q", existing, skill, level, code)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+func getAllRemarksV2(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT * FROM remarks WHERE archived = 0"
+	if r.URL.Query().Get("archived") == "true" {
+		query = "SELECT * FROM remarks"
+	}
+	listV2(w, r, queryRemarks, v2.FromRemark, query)
+}
+
+func getRemarkV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadRemark, v2.FromRemark)
+}
+
+func createRemarkV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.RemarkInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var remark Remark
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateRemark(tx, 0, input.SkillId, input.Level, input.Description, input.Code)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO skills (id) VALUES(?)", input.SkillId)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO remarks (skill, level, description, code) VALUES(?, ?, ?, ?)", input.SkillId, input.Level, input.Description, input.Code)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		remark, err = loadRemark(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/remarks/"+strconv.FormatInt(remark.Id, 10), v2.FromRemark(remark))
+}
+
+func updateRemarkV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.RemarkPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var remark Remark
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadRemark(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		remark = current
+		patchField(&remark.Skill, patch.SkillId)
+		patchField(&remark.Level, patch.Level)
+		patchField(&remark.Description, patch.Description)
+		patchField(&remark.Code, patch.Code)
+		patchField(&remark.Archived, patch.Archived)
+		err = validateRemark(tx, remark.Id, remark.Skill, remark.Level, remark.Description, remark.Code)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT OR IGNORE INTO skills (id) VALUES(?)", remark.Skill)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE remarks SET skill = ?, level = ?, description = ?, code = ?, archived = ? WHERE id = ?", remark.Skill, remark.Level, remark.Description, remark.Code, remark.Archived, remark.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Remark]{Data: v2.FromRemark(remark)})
+}
+
+func deleteRemarkV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		remark, err := loadRemark(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		err = checkUnobserved(tx, "remark", remark.Id, "archive it instead")
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "remarks", remark.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Skills
+
+func getAllSkillsV2(w http.ResponseWriter, r *http.Request) {
+	listV2(w, r, querySkills, v2.FromSkill, "SELECT * FROM skills")
+}
+
+func updateSkillV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.SkillPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var skill Skill
+	err = withTx(func(tx *sql.Tx) error {
+		var err error
+		skill, err = loadSkill(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		patchField(&skill.Name, patch.Name)
+		switch {
+		case patch.SubjectId == nil:
+		case *patch.SubjectId == 0:
+			skill.Subject = nil
+		default:
+			if err := checkExists(tx, "subject", *patch.SubjectId); err != nil {
+				return err
+			}
+			skill.Subject = patch.SubjectId
+		}
+		switch {
+		case patch.NodeId == nil:
+		case *patch.NodeId == 0:
+			skill.Node = nil
+		default:
+			if err := checkLeaf(tx, *patch.NodeId); err != nil {
+				return err
+			}
+			skill.Node = patch.NodeId
+		}
+		_, err = tx.Exec("UPDATE skills SET name = ?, subject = ?, node = ? WHERE id = ?", skill.Name, skill.Subject, skill.Node, skill.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Skill]{Data: v2.FromSkill(skill)})
+}
+
+// Observations
+
+const NOTES_MAX_LENGTH = 10000
+
+func validateNotes(notes string) error {
+	if utf8.RuneCountInString(notes) > NOTES_MAX_LENGTH {
+		return invalid("notes can be at most %d characters", NOTES_MAX_LENGTH)
+	}
+	return nil
+}
+
+func validateObservation(q querier, teacherId int64, studentId int64, remarkId int64, outcome string) error {
+	if err := checkExists(q, "teacher", teacherId); err != nil {
+		return err
+	}
+	if err := checkExists(q, "student", studentId); err != nil {
+		return err
+	}
+	if err := checkExists(q, "remark", remarkId); err != nil {
+		return err
+	}
+	return validateOutcome(q, outcome)
+}
+
+func getAllObservationsV2(w http.ResponseWriter, r *http.Request) {
+	listObservationsV2(w, r, nil)
+}
+
+func getObservationsOnStudentV2(w http.ResponseWriter, r *http.Request) {
+	listObservationsV2(w, r, []string{"student = ?"}, r.PathValue("id"))
+}
+
+func getObservationsByTeacherV2(w http.ResponseWriter, r *http.Request) {
+	listObservationsV2(w, r, []string{"teacher = ?"}, r.PathValue("id"))
+}
+
+func getObservationsByTeacherOnStudentV2(w http.ResponseWriter, r *http.Request) {
+	listObservationsV2(w, r, []string{"teacher = ?", "student = ?"}, r.PathValue("teacherId"), r.PathValue("studentId"))
+}
+
+func getObservationV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadObservation, v2.FromObservation)
+}
+
+func createObservationV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.ObservationInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var observation Observation
+	err = withTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		date := now
+		if input.Date != nil {
+			date = *input.Date
+		}
+		err := validateObservation(tx, input.TeacherId, input.StudentId, input.RemarkId, input.Outcome)
+		if err != nil {
+			return err
+		}
+		err = validateObservationDate(tx, date)
+		if err != nil {
+			return err
+		}
+		err = checkTeaches(tx, input.TeacherId, input.StudentId, input.RemarkId, date)
+		if err != nil {
+			return err
+		}
+		err = validateNotes(input.Notes)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO observations (teacher, student, remark, outcome, score, date, recorded_at, notes) VALUES(?, ?, ?, ?, ?, ?, ?, ?)", input.TeacherId, input.StudentId, input.RemarkId, input.Outcome, input.Score, sqliteTime(date), sqliteTime(now), input.Notes)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, requestUser(r), id, "create", nil)
+		if err != nil {
+			return err
+		}
+		observation, err = loadObservation(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/observations/"+strconv.FormatInt(observation.Id, 10), v2.FromObservation(observation))
+}
+
+func updateObservationV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.ObservationPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var observation Observation
+	err = withTx(func(tx *sql.Tx) error {
+		var current Observation
+		err := scanObservation(tx.QueryRow("SELECT * FROM observations WHERE id = ?", r.PathValue("id")), &current)
+		if err != nil {
+			return err
+		}
+		teacherId, studentId, remarkId, outcome, score, notes := current.Teacher.Id, current.Student.Id, current.Remark.Id, current.Outcome, current.Score, current.Notes
+		patchField(&teacherId, patch.TeacherId)
+		patchField(&studentId, patch.StudentId)
+		patchField(&remarkId, patch.RemarkId)
+		patchField(&outcome, patch.Outcome)
+		patchField(&notes, patch.Notes)
+		if patch.Score != nil {
+			score = patch.Score
+		}
+		err = validateObservation(tx, teacherId, studentId, remarkId, outcome)
+		if err != nil {
+			return err
+		}
+		err = validateNotes(notes)
+		if err != nil {
+			return err
+		}
+		date := current.Date
+		if patch.Date != nil {
+			date = *patch.Date
+			if err := validateObservationDate(tx, date); err != nil {
+				return err
+			}
+		}
+		if teacherId != current.Teacher.Id || studentId != current.Student.Id || remarkId != current.Remark.Id || !date.Equal(current.Date) {
+			if err := checkTeaches(tx, teacherId, studentId, remarkId, date); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE observations SET teacher = ?, student = ?, remark = ?, outcome = ?, score = ?, date = ?, notes = ? WHERE id = ?", teacherId, studentId, remarkId, outcome, score, sqliteTime(date), notes, current.Id)
+		if err != nil {
+			return err
+		}
+		err = recordRevision(tx, requestUser(r), current.Id, "update", nil)
+		if err != nil {
+			return err
+		}
+		observation, err = loadObservation(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Observation]{Data: v2.FromObservation(observation)})
+}
+
+func deleteObservationV2(w http.ResponseWriter, r *http.Request) {
+	var blobs []string
+	err := withTx(func(tx *sql.Tx) error {
+		var id int64
+		err := tx.QueryRow("SELECT id FROM observations WHERE id = ?", r.PathValue("id")).Scan(&id)
+		if err != nil {
+			return err
+		}
+		blobs, err = deleteObservationEvidence(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(tx, requestUser(r), id, "delete", nil); err != nil {
+			return err
+		}
+		return deleteRow(tx, "observations", id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	releaseBlobs(blobs...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Marks responses of v1 routes that have a v2 counterpart as deprecated
+// (RFC 9745) and links the counterpart. With api_v1_sunset set, the date v1
+// goes away is announced too (RFC 8594).
+func deprecateV1(mux *router, next http.Handler) http.Handler {
+	deprecated := map[string]bool{}
+	for _, pattern := range mux.patterns {
+		counterpart, ok := v2Counterpart(pattern)
+		if ok && mux.has(counterpart) {
+			deprecated[pattern] = true
+		}
+	}
+	deprecation := "@" + strconv.FormatInt(V1_DEPRECATED.Unix(), 10)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); deprecated[pattern] {
+			counterpart, _ := v2Counterpart(r.URL.Path)
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Add("Link", "<"+counterpart+`>; rel="successor-version"`)
+			if sunset, ok := CONFIG.v1Sunset(); ok {
+				w.Header().Set("Sunset", sunset.Format(http.TimeFormat))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
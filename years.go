@@ -0,0 +1,364 @@
+package main
+
+import (
+	"api/entities/v2"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// School years split into terms. Both are spans of whole days in the
+// school's time zone, end day included. Observations belong to the term
+// containing their date, classes to a year.
+
+// A span of time lists can be narrowed to with ?term_id= or ?year_id=.
+// First and Last are its days, Start and End the midnights opening and
+// closing it.
+type period struct {
+	Year  int64
+	First string
+	Last  string
+	Start time.Time
+	End   time.Time
+}
+
+func periodOf(year int64, start string, end string) period {
+	location := CONFIG.location()
+	first, _ := time.ParseInLocation(time.DateOnly, start, location)
+	last, _ := time.ParseInLocation(time.DateOnly, end, location)
+	return period{Year: year, First: start, Last: end, Start: first, End: last.AddDate(0, 0, 1)}
+}
+
+// Returns the period of ?term_id= or ?year_id=, ok is false without either.
+func requestPeriod(q querier, r *http.Request) (period, bool, error) {
+	termId, yearId := r.URL.Query().Get("term_id"), r.URL.Query().Get("year_id")
+	switch {
+	case termId != "" && yearId != "":
+		return period{}, false, invalid("filter by term_id or year_id, not both")
+	case termId != "":
+		id, err := strconv.ParseInt(termId, 10, 64)
+		if err != nil {
+			return period{}, false, fmt.Errorf("term_id must be an integer")
+		}
+		term, err := loadTerm(q, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return period{}, false, invalid("term %d doesn't exist", id)
+		}
+		if err != nil {
+			return period{}, false, err
+		}
+		return periodOf(term.Year, term.Start, term.End), true, nil
+	case yearId != "":
+		id, err := strconv.ParseInt(yearId, 10, 64)
+		if err != nil {
+			return period{}, false, fmt.Errorf("year_id must be an integer")
+		}
+		year, err := loadSchoolYear(q, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return period{}, false, invalid("school year %d doesn't exist", id)
+		}
+		if err != nil {
+			return period{}, false, err
+		}
+		return periodOf(year.Id, year.Start, year.End), true, nil
+	}
+	return period{}, false, nil
+}
+
+// Adds to conditions the ones keeping rows of table in the period of the
+// request: observations by date, classes by year, students enrolled in a
+// class of the year during the period and teachers of the year's classes.
+func periodFilter(q querier, r *http.Request, table string, conditions []string, args []any) ([]string, []any, error) {
+	span, ok, err := requestPeriod(q, r)
+	if err != nil || !ok {
+		return conditions, args, err
+	}
+	switch table {
+	case "observations":
+		conditions = append(conditions, "datetime(date) >= ? AND datetime(date) < ?")
+		args = append(args, sqliteTime(span.Start), sqliteTime(span.End))
+	case "classes":
+		conditions = append(conditions, "year = ?")
+		args = append(args, span.Year)
+	case "students":
+		conditions = append(conditions, "id IN (SELECT e.student FROM enrollments e JOIN classes c ON c.id = e.class WHERE c.year = ? AND (e.start_date IS NULL OR e.start_date <= ?) AND (e.end_date IS NULL OR e.end_date >= ?))")
+		args = append(args, span.Year, span.Last, span.First)
+	case "teachers":
+		conditions = append(conditions, "id IN (SELECT teacher_id FROM classes_teachers WHERE class_id IN (SELECT id FROM classes WHERE year = ?))")
+		args = append(args, span.Year)
+	}
+	return conditions, args, nil
+}
+
+func validateDays(start string, end string) error {
+	first, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return invalid("start_date %q is not a YYYY-MM-DD date", start)
+	}
+	last, err := time.Parse(time.DateOnly, end)
+	if err != nil {
+		return invalid("end_date %q is not a YYYY-MM-DD date", end)
+	}
+	if last.Before(first) {
+		return invalid("end_date %s is before start_date %s", end, start)
+	}
+	return nil
+}
+
+// id is the year being updated, 0 for a new one. Years can't overlap and
+// must keep their terms inside them.
+func validateSchoolYear(q querier, id int64, name string, start string, end string) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if err := validateDays(start, end); err != nil {
+		return err
+	}
+	var other string
+	err := q.QueryRow("SELECT name FROM school_years WHERE id != ? AND start_date <= ? AND end_date >= ?", id, end, start).Scan(&other)
+	if err == nil {
+		return conflict("school year %s overlaps %s", name, other)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	err = q.QueryRow("SELECT name FROM terms WHERE year = ? AND (start_date < ? OR end_date > ?)", id, start, end).Scan(&other)
+	if err == nil {
+		return conflict("term %s would fall outside the school year", other)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// id is the term being updated, 0 for a new one. Terms lie within their
+// year and don't overlap.
+func validateTerm(q querier, id int64, yearId int64, name string, start string, end string) error {
+	if err := requireText("name", name); err != nil {
+		return err
+	}
+	if err := validateDays(start, end); err != nil {
+		return err
+	}
+	year, err := loadSchoolYear(q, yearId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalid("school year %d doesn't exist", yearId)
+	}
+	if err != nil {
+		return err
+	}
+	if start < year.Start || end > year.End {
+		return invalid("term %s must lie within school year %s, %s to %s", name, year.Name, year.Start, year.End)
+	}
+	var other string
+	err = q.QueryRow("SELECT name FROM terms WHERE id != ? AND start_date <= ? AND end_date >= ?", id, end, start).Scan(&other)
+	if err == nil {
+		return conflict("term %s overlaps %s", name, other)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// School years
+
+func getAllSchoolYearsV2(w http.ResponseWriter, r *http.Request) {
+	listV2(w, r, querySchoolYears, v2.FromSchoolYear, "SELECT * FROM school_years")
+}
+
+func getSchoolYearV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadSchoolYear, v2.FromSchoolYear)
+}
+
+func createSchoolYearV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.SchoolYearInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var year SchoolYear
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateSchoolYear(tx, 0, input.Name, input.StartDate, input.EndDate)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO school_years (name, start_date, end_date) VALUES(?, ?, ?)", input.Name, input.StartDate, input.EndDate)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		year, err = loadSchoolYear(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/years/"+strconv.FormatInt(year.Id, 10), v2.FromSchoolYear(year))
+}
+
+func updateSchoolYearV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.SchoolYearPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var year SchoolYear
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadSchoolYear(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		name, start, end := current.Name, current.Start, current.End
+		patchField(&name, patch.Name)
+		patchField(&start, patch.StartDate)
+		patchField(&end, patch.EndDate)
+		err = validateSchoolYear(tx, current.Id, name, start, end)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE school_years SET name = ?, start_date = ?, end_date = ? WHERE id = ?", name, start, end, current.Id)
+		if err != nil {
+			return err
+		}
+		year, err = loadSchoolYear(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.SchoolYear]{Data: v2.FromSchoolYear(year)})
+}
+
+func deleteSchoolYearV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		year, err := loadSchoolYear(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"terms", "classes"} {
+			var count int
+			err := tx.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE year = ?", year.Id).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return conflict("school year %d still has %d %s, delete or move them first", year.Id, count, table)
+			}
+		}
+		return deleteRow(tx, "school_years", year.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Terms
+
+func getAllTermsV2(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("year_id")
+	if value == "" {
+		listV2(w, r, queryTerms, v2.FromTerm, "SELECT * FROM terms")
+		return
+	}
+	yearId, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		errorCheckV2(&w, fmt.Errorf("year_id must be an integer"), 400)
+		return
+	}
+	listV2(w, r, queryTerms, v2.FromTerm, "SELECT * FROM terms WHERE year = ?", yearId)
+}
+
+func getTermV2(w http.ResponseWriter, r *http.Request) {
+	getV2(w, r, loadTerm, v2.FromTerm)
+}
+
+func createTermV2(w http.ResponseWriter, r *http.Request) {
+	var input v2.TermInput
+	err := decodeV2(r, &input)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var term Term
+	err = withTx(func(tx *sql.Tx) error {
+		err := validateTerm(tx, 0, input.YearId, input.Name, input.StartDate, input.EndDate)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("INSERT INTO terms (year, name, start_date, end_date) VALUES(?, ?, ?, ?)", input.YearId, input.Name, input.StartDate, input.EndDate)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		term, err = loadTerm(tx, id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeCreatedV2(w, "/api/v2/terms/"+strconv.FormatInt(term.Id, 10), v2.FromTerm(term))
+}
+
+func updateTermV2(w http.ResponseWriter, r *http.Request) {
+	var patch v2.TermPatch
+	err := decodeV2(r, &patch)
+	if errorCheckV2(&w, err, 400) {
+		return
+	}
+
+	var term Term
+	err = withTx(func(tx *sql.Tx) error {
+		current, err := loadTerm(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		name, start, end := current.Name, current.Start, current.End
+		patchField(&name, patch.Name)
+		patchField(&start, patch.StartDate)
+		patchField(&end, patch.EndDate)
+		err = validateTerm(tx, current.Id, current.Year, name, start, end)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE terms SET name = ?, start_date = ?, end_date = ? WHERE id = ?", name, start, end, current.Id)
+		if err != nil {
+			return err
+		}
+		term, err = loadTerm(tx, current.Id)
+		return err
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	writeV2(w, http.StatusOK, v2.Data[v2.Term]{Data: v2.FromTerm(term)})
+}
+
+// Observations only point at terms through their date, so deleting a term
+// just leaves them without one.
+func deleteTermV2(w http.ResponseWriter, r *http.Request) {
+	err := withTx(func(tx *sql.Tx) error {
+		term, err := loadTerm(tx, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+		return deleteRow(tx, "terms", term.Id)
+	})
+	if errorCheckV2(&w, err, 500) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
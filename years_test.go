@@ -0,0 +1,167 @@
+package main
+
+import (
+	"api/client"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPeriodFilters(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	c := newTestClient(t, server, client.WithCredentials("tester", "secret"))
+	day := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format(time.DateOnly)
+	}
+
+	old, err := c.CreateSchoolYear(ctx, client.SchoolYearInput{Name: "last year", StartDate: day(-500), EndDate: day(-200)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	year, err := c.CreateSchoolYear(ctx, client.SchoolYearInput{Name: "this year", StartDate: day(-60), EndDate: day(200)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var classes []client.Class
+	var teachers []client.Teacher
+	var students []client.Student
+	for _, yearId := range []int64{year.Id, old.Id} {
+		class, err := c.CreateClass(ctx, client.ClassInput{Name: "1A", YearId: &yearId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		teacher, err := c.CreateTeacher(ctx, client.TeacherInput{Name: "Maria", Surname: "Bianchi", ClassIds: []int64{class.Id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		student, err := c.CreateStudent(ctx, client.StudentInput{Name: "Ada", Surname: "Rossi", ClassId: class.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		classes, teachers, students = append(classes, class), append(teachers, teacher), append(students, student)
+	}
+	remark, err := c.CreateRemark(ctx, client.RemarkInput{SkillId: 1, Level: 1, Description: "Hands work in on time", Code: "on-time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dated in the first and second term, before terms exist to refuse
+	// the first date
+	var observations []int64
+	for _, days := range []int{-40, -10} {
+		date := time.Now().AddDate(0, 0, days)
+		observation, err := c.CreateObservation(ctx, client.ObservationInput{TeacherId: teachers[0].Id, StudentId: students[0].Id, RemarkId: remark.Id, Outcome: "achieved", Date: &date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		observations = append(observations, observation.Id)
+	}
+	var terms []client.Term
+	for i, span := range [][2]int{{-60, -31}, {-30, 200}} {
+		term, err := c.CreateTerm(ctx, client.TermInput{YearId: year.Id, Name: "term " + strconv.Itoa(i+1), StartDate: day(span[0]), EndDate: day(span[1])})
+		if err != nil {
+			t.Fatal(err)
+		}
+		terms = append(terms, term)
+	}
+
+	ids := func(t *testing.T, list any, err error) []int64 {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []int64
+		switch list := list.(type) {
+		case []client.Observation:
+			for _, item := range list {
+				found = append(found, item.Id)
+			}
+		case []client.Student:
+			for _, item := range list {
+				found = append(found, item.Id)
+			}
+		case []client.Teacher:
+			for _, item := range list {
+				found = append(found, item.Id)
+			}
+		case []client.Class:
+			for _, item := range list {
+				found = append(found, item.Id)
+			}
+		}
+		return found
+	}
+	for _, test := range []struct {
+		name   string
+		period client.Period
+		list   func(client.Period) (any, error)
+		want   []int64
+	}{
+		{"observations in the first term", client.Period{TermId: terms[0].Id}, func(p client.Period) (any, error) {
+			return c.ListObservationsWhere(ctx, client.ObservationFilter{Period: p})
+		}, observations[:1]},
+		{"observations in the second term", client.Period{TermId: terms[1].Id}, func(p client.Period) (any, error) {
+			return c.ListObservationsWhere(ctx, client.ObservationFilter{Period: p})
+		}, observations[1:]},
+		{"observations in the year", client.Period{YearId: year.Id}, func(p client.Period) (any, error) {
+			return c.ListObservationsWhere(ctx, client.ObservationFilter{Period: p})
+		}, observations},
+		{"observations last year", client.Period{YearId: old.Id}, func(p client.Period) (any, error) {
+			return c.ListObservationsWhere(ctx, client.ObservationFilter{Period: p})
+		}, nil},
+		{"students in a term", client.Period{TermId: terms[0].Id}, func(p client.Period) (any, error) {
+			return c.ListStudentsIn(ctx, p)
+		}, []int64{students[0].Id}},
+		{"students last year", client.Period{YearId: old.Id}, func(p client.Period) (any, error) {
+			return c.ListStudentsIn(ctx, p)
+		}, []int64{students[1].Id}},
+		{"teachers in the year", client.Period{YearId: year.Id}, func(p client.Period) (any, error) {
+			return c.ListTeachersIn(ctx, p)
+		}, []int64{teachers[0].Id}},
+		{"classes last year", client.Period{YearId: old.Id}, func(p client.Period) (any, error) {
+			return c.ListClasses(ctx, p)
+		}, []int64{classes[1].Id}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			list, err := test.list(test.period)
+			got := ids(t, list, err)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+
+	_, err = c.ListStudentsIn(ctx, client.Period{TermId: terms[0].Id, YearId: year.Id})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got %v, want a 422 APIError for both term_id and year_id", err)
+	}
+
+	// v1 lists take the same filters
+	request, err := http.NewRequest("GET", server.URL+"/api/observations?term_id="+strconv.FormatInt(terms[0].Id, 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.SetBasicAuth("tester", "secret")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var v1 []Observation
+	if err := json.NewDecoder(response.Body).Decode(&v1); err != nil {
+		t.Fatal(err)
+	}
+	if len(v1) != 1 || v1[0].Id != observations[0] {
+		t.Errorf("v1 got %d observations in the first term, want observation %d", len(v1), observations[0])
+	}
+}